@@ -0,0 +1,119 @@
+package dualpng
+
+import (
+	"image"
+	"image/color"
+)
+
+// MergeAlpha merges two images by crafting the alpha and colour of every pixel
+// so that the result composited over bg1 shows img1, and composited over bg2
+// shows img2. Where both can not be satisfied exactly the mask decides which
+// image is favoured: opaque mask pixels favour img1, transparent ones img2.
+// If maskmatrix is nil DefaultMask is used.
+//     img1       : image visible on bg1
+//     img2       : image visible on bg2
+//     bg1        : first background colour
+//     bg2        : second background colour
+//     maskmatrix : Mask weighting the two images
+func MergeAlpha(img1, img2 image.Image, bg1, bg2 color.Color, maskmatrix [][]float64) *image.NRGBA {
+	b := img1.Bounds().Union(img2.Bounds())
+	b = b.Sub(b.Min)
+	out := image.NewNRGBA(b)
+	mergeAlpha(img1, img2, bg1, bg2, maskmatrix, b, func(x, y int, c [3]float64, a float64) {
+		i := out.PixOffset(x, y)
+		for k := 0; k < 3; k++ {
+			out.Pix[i+k] = uint8(c[k]*255 + 0.5)
+		}
+		out.Pix[i+3] = uint8(a*255 + 0.5)
+	})
+	return out
+}
+
+// MergeAlpha16 is MergeAlpha for the 16 bit pipeline.
+//     img1       : image visible on bg1
+//     img2       : image visible on bg2
+//     bg1        : first background colour
+//     bg2        : second background colour
+//     maskmatrix : Mask weighting the two images
+func MergeAlpha16(img1, img2 image.Image, bg1, bg2 color.Color, maskmatrix [][]float64) *image.NRGBA64 {
+	b := img1.Bounds().Union(img2.Bounds())
+	b = b.Sub(b.Min)
+	out := image.NewNRGBA64(b)
+	mergeAlpha(img1, img2, bg1, bg2, maskmatrix, b, func(x, y int, c [3]float64, a float64) {
+		i := out.PixOffset(x, y)
+		for k, v := range [4]float64{c[0], c[1], c[2], a} {
+			n := uint16(v*0xffff + 0.5)
+			out.Pix[i+2*k+0] = uint8(n >> 8)
+			out.Pix[i+2*k+1] = uint8(n)
+		}
+	})
+	return out
+}
+
+// mergeAlpha solves the colour and alpha of every pixel in b for MergeAlpha
+// and passes them to set. Pixels that are fully transparent are skipped.
+// The colour components are between 0 and 1 and not alpha premultiplied.
+func mergeAlpha(img1, img2 image.Image, bg1, bg2 color.Color, maskmatrix [][]float64, b image.Rectangle, set func(x, y int, c [3]float64, a float64)) {
+	if maskmatrix == nil {
+		maskmatrix = DefaultMask
+	}
+	mask := newTiledMask(maskmatrix)
+
+	A := unpremultiply(bg1)
+	B := unpremultiply(bg2)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p1 := over(unpremultiply(img1.At(x, y)), A)
+			p2 := over(unpremultiply(img2.At(x, y)), B)
+			w := float64(mask.weight(x, y)) / 255
+
+			// Composited over a background a pixel with colour c and alpha a is
+			// a*c + (1-a)*bg. Requiring p1 over A and p2 over B gives
+			// (1-a)*(A-B) = p1-p2 for each channel.
+			var k float64
+			n := 0
+			for c := 0; c < 3; c++ {
+				if d := A[c] - B[c]; d != 0 {
+					k += (p1[c] - p2[c]) / d
+					n++
+				}
+			}
+			a := 1.0
+			if n > 0 {
+				a = clamp(1-k/float64(n), 0, 1)
+			}
+			if a == 0 {
+				continue
+			}
+
+			var out [3]float64
+			for c := range out {
+				c1 := (p1[c] - (1-a)*A[c]) / a
+				c2 := (p2[c] - (1-a)*B[c]) / a
+				out[c] = clamp(w*c1+(1-w)*c2, 0, 1)
+			}
+			set(x, y, out, a)
+		}
+	}
+}
+
+// unpremultiply returns the non alpha premultiplied components of c between 0 and 1.
+func unpremultiply(c color.Color) [4]float64 {
+	n := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+	return [4]float64{
+		float64(n.R) / 0xffff,
+		float64(n.G) / 0xffff,
+		float64(n.B) / 0xffff,
+		float64(n.A) / 0xffff,
+	}
+}
+
+// over composites the colour c over the opaque background bg.
+func over(c, bg [4]float64) [3]float64 {
+	return [3]float64{
+		c[3]*c[0] + (1-c[3])*bg[0],
+		c[3]*c[1] + (1-c[3])*bg[1],
+		c[3]*c[2] + (1-c[3])*bg[2],
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/Necroforger/dualpng/webui"
+)
+
+// Flags
+var (
+	Port = flag.String("p", "8800", "Server port")
+	Dir  = flag.String("d", "", "Asset directory, If none provided, the embedded ui will be run")
+)
+
+// The web UI is also served by `dualpng serve`.
+func main() {
+	flag.Parse()
+
+	srv := &webui.Server{Dir: *Dir}
+	log.Println("Starting server on port [" + ":" + *Port + "]")
+	log.Println("Connect to http://localhost:" + *Port + "/ in your browser")
+	if err := srv.ListenAndServe("127.0.0.1:" + *Port); err != nil {
+		log.Println("error starting server: ", err)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchRow is a pair of images to merge, read from a manifest.
+// Flags overrides the merge flags given to the batch command.
+type batchRow struct {
+	Img1   string     `json:"img1"`
+	Img2   string     `json:"img2"`
+	Output string     `json:"output"`
+	Flags  flagValues `json:"flags,omitempty"`
+}
+
+// batchResult is the outcome of a row, written to the report.
+type batchResult struct {
+	Row     int     `json:"row"`
+	Img1    string  `json:"img1"`
+	Img2    string  `json:"img2"`
+	Output  string  `json:"output"`
+	Status  string  `json:"status"`
+	Error   string  `json:"error,omitempty"`
+	Seconds float64 `json:"seconds"`
+}
+
+// readManifest reads the rows of a JSON manifest, or of a CSV manifest
+// if the path ends in .csv.
+func readManifest(path string) ([]batchRow, error) {
+	source, err := openSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readCSVManifest(source)
+	}
+	var rows []batchRow
+	if err := json.NewDecoder(source).Decode(&rows); err != nil {
+		return nil, decodeError(errors.New("Invalid manifest: " + err.Error()))
+	}
+	return rows, nil
+}
+
+// readCSVManifest reads a CSV manifest. The header names the img1, img2
+// and output columns, and every other column is a flag. Empty cells do not
+// override the flag.
+func readCSVManifest(r io.Reader) ([]batchRow, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, decodeError(errors.New("Invalid manifest: " + err.Error()))
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	var rows []batchRow
+	for _, record := range records[1:] {
+		var row batchRow
+		for i, v := range record {
+			switch name := strings.TrimSpace(header[i]); name {
+			case "img1":
+				row.Img1 = v
+			case "img2":
+				row.Img2 = v
+			case "output", "o":
+				row.Output = v
+			default:
+				if v == "" {
+					continue
+				}
+				if row.Flags == nil {
+					row.Flags = flagValues{}
+				}
+				row.Flags[strings.TrimPrefix(name, "-")] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// runRow merges the images of a row. args are the merge flags given to the
+// batch command, which the row's flags override.
+func runRow(row batchRow, args []string) error {
+	if row.Img1 == "" || row.Img2 == "" {
+		return errors.New("Row needs img1 and img2")
+	}
+	if row.Output == "" {
+		return errors.New("Row needs an output path")
+	}
+	fs := flag.NewFlagSet("row", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	f := newMergeFlags(fs)
+
+	// A row that picks a mask replaces the mask given to the batch command.
+	var masked bool
+	for _, name := range []string{"m", "mi", "pattern"} {
+		_, ok := row.Flags[name]
+		masked = masked || ok
+	}
+	var rowArgs []string
+	for _, arg := range args {
+		if masked && (strings.HasPrefix(arg, "-m=") || strings.HasPrefix(arg, "-mi=") || strings.HasPrefix(arg, "-pattern=")) {
+			continue
+		}
+		rowArgs = append(rowArgs, arg)
+	}
+	args = rowArgs
+	for name, v := range row.Flags {
+		if name == "o" {
+			return errors.New("Use the output column instead of the o flag")
+		}
+		if fs.Lookup(name) == nil {
+			return errors.New("Unknown flag: " + name)
+		}
+		args = append(args, "-"+name+"="+v)
+	}
+	args = append(args, "-o="+row.Output)
+	if err := fs.Parse(args); err != nil {
+		return usageError(err)
+	}
+	if fs.NArg() > 0 {
+		return errors.New("Unexpected argument: " + fs.Arg(0))
+	}
+	return f.merge(row.Img1, row.Img2)
+}
+
+// batch merges every pair of images listed in a manifest.
+func batch(args []string) error {
+	fs := newFlagSet("batch", "[flags] manifest.json|manifest.csv",
+		"Merges every pair of images listed in a JSON or CSV manifest. The merge flags apply to every\n"+
+			"row unless the row overrides them. A report of every row is written when all rows are done.\n"+
+			"Rows run at the same time, so their images and outputs can not be \"-\".")
+	var (
+		workers = fs.Int("workers", runtime.NumCPU(), "Number of rows processed at the same time")
+		report  = fs.String("report", "", "Path of the report. JSON unless it ends in .csv. Empty prints JSON to stdout")
+	)
+	newMergeFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return usageError(errors.New("Expected one manifest"))
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	// Pass the merge flags given to the batch command on to every row.
+	var common []string
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "workers", "report", "o":
+		default:
+			common = append(common, "-"+fl.Name+"="+fl.Value.String())
+		}
+	})
+	if fs.Lookup("o").Value.String() != "" {
+		return usageError(errors.New("The output of each row is set in the manifest"))
+	}
+
+	// The report is written to stdout when no path is given.
+	if *report == "" {
+		if err := claimStdout(); err != nil {
+			return err
+		}
+	}
+
+	rows, err := readManifest(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// Rows run at the same time, so none of them can have stdin or stdout.
+	for i, row := range rows {
+		if row.Img1 == "-" || row.Img2 == "-" || row.Output == "-" {
+			return usageError(errors.New("Row " + strconv.Itoa(i+1) + " can not read from stdin or write to stdout"))
+		}
+	}
+
+	// Rows writing the same output would overwrite each other.
+	results := make([]batchResult, len(rows))
+	outputs := map[string]int{}
+	for i, row := range rows {
+		results[i] = batchResult{Row: i + 1, Img1: row.Img1, Img2: row.Img2, Output: row.Output}
+		if row.Output == "" {
+			continue
+		}
+		key := filepath.Clean(row.Output)
+		if first, ok := outputs[key]; ok {
+			results[i].Status = "failed"
+			results[i].Error = "Output is also written by row " + strconv.Itoa(first)
+			continue
+		}
+		outputs[key] = i + 1
+	}
+
+	start := time.Now()
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				t := time.Now()
+				err := runRow(rows[i], common)
+				results[i].Seconds = time.Since(t).Seconds()
+				if err != nil {
+					results[i].Status = "failed"
+					results[i].Error = err.Error()
+				} else {
+					results[i].Status = "ok"
+				}
+			}
+		}()
+	}
+	for i := range rows {
+		if results[i].Status == "" {
+			queue <- i
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Status != "ok" {
+			failed++
+			log.Printf("row %d: %s", r.Row, r.Error)
+		}
+	}
+	if err := writeReport(*report, results); err != nil {
+		return err
+	}
+	log.Printf("batch: %d of %d rows succeeded in %s",
+		len(rows)-failed, len(rows), time.Since(start).Round(time.Millisecond))
+	if failed > 0 {
+		return errors.New(strconv.Itoa(failed) + " rows failed")
+	}
+	return nil
+}
+
+// writeReport writes the results as CSV if path ends in .csv and as JSON
+// otherwise. An empty path writes JSON to stdout.
+func writeReport(path string, results []batchResult) error {
+	if path == "" {
+		return writeJSONReport(os.Stdout, results)
+	}
+	return writeFile(path, func(w io.Writer) error {
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			return writeCSVReport(w, results)
+		}
+		return writeJSONReport(w, results)
+	})
+}
+
+func writeJSONReport(w io.Writer, results []batchResult) error {
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func writeCSVReport(w io.Writer, results []batchResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"row", "img1", "img2", "output", "status", "error", "seconds"})
+	for _, r := range results {
+		cw.Write([]string{
+			strconv.Itoa(r.Row), r.Img1, r.Img2, r.Output, r.Status, r.Error,
+			strconv.FormatFloat(r.Seconds, 'f', 3, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
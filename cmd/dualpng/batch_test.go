@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// resetStdio forgets that stdin and stdout have been claimed.
+func resetStdio() {
+	stdio.Lock()
+	stdio.in, stdio.out = false, false
+	stdio.Unlock()
+}
+
+func TestBatchStdio(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dualpng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out.png")
+	report := filepath.Join(dir, "report.json")
+	tests := []struct {
+		name    string
+		row     batchRow
+		report  string
+		claimed bool
+		code    int
+	}{
+		{"ok", batchRow{Img1: testImg1, Img2: testImg2, Output: out}, report, false, 0},
+		{"output to stdout", batchRow{Img1: testImg1, Img2: testImg2, Output: "-"}, report, false, exitUsage},
+		{"img1 from stdin", batchRow{Img1: "-", Img2: testImg2, Output: out}, report, false, exitUsage},
+		{"img2 from stdin", batchRow{Img1: testImg1, Img2: "-", Output: out}, report, false, exitUsage},
+		{"report to taken stdout", batchRow{Img1: testImg1, Img2: testImg2, Output: out}, "", true, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStdio()
+			defer resetStdio()
+			os.Remove(out)
+
+			manifest := filepath.Join(dir, "manifest.json")
+			b, err := json.Marshal([]batchRow{tt.row})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(manifest, b, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.claimed {
+				claimStdout()
+			}
+
+			code := 0
+			if err := run([]string{"batch", "-w", "20", "-report=" + tt.report, manifest}); err != nil {
+				code = exitCode(err)
+			}
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d", code, tt.code)
+			}
+			if _, err := os.Stat(out); (err == nil) != (tt.code == 0) {
+				t.Errorf("output exists: %v, want %v", err == nil, tt.code == 0)
+			}
+		})
+	}
+}
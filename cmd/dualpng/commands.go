@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	dp "github.com/Necroforger/dualpng"
+	"github.com/Necroforger/dualpng/webui"
+)
+
+// A command is a subcommand of dualpng.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands returns the subcommands in the order the help lists them.
+func commands() []command {
+	return []command{
+		{"merge", "Merge two images into a dual png. Runs when no command is given", merge},
+		{"preview", "Render how a dual png looks with and without gamma correction", preview},
+		{"inspect", "Print the chunks, gAMA value and parameters of a png", inspect},
+		{"solve", "Choose the gAMA value and colour ranges for two images", solve},
+		{"serve", "Run the web UI", serve},
+		{"presets", "List the presets or show the flags of one", presets},
+		{"batch", "Merge every pair of images listed in a manifest", batch},
+	}
+}
+
+// run runs the command with the arguments that follow the program name.
+func run(args []string) error {
+	if len(args) > 0 {
+		if args[0] == "help" {
+			return help(args[1:])
+		}
+		for _, c := range commands() {
+			if args[0] == c.name {
+				return c.run(args[1:])
+			}
+		}
+	}
+	return merge(args)
+}
+
+// help prints the commands, or the help of the named command.
+func help(args []string) error {
+	if len(args) == 0 {
+		w := os.Stderr
+		fmt.Fprintln(w, "Usage: dualpng [command] [flags] [arguments]")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Commands:")
+		for _, c := range commands() {
+			fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, `Run "dualpng help command" for the flags of a command.`)
+		return nil
+	}
+	for _, c := range commands() {
+		if args[0] == c.name {
+			return c.run([]string{"-help"})
+		}
+	}
+	return usageError(errors.New("Unknown command: " + args[0]))
+}
+
+// newFlagSet returns the flag set of a command, whose usage shows the
+// arguments and a description of the command above its flags.
+func newFlagSet(name, arguments, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: dualpng %s %s\n\n%s\n\nFlags:\n", name, arguments, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args with fs, which must continue on errors.
+// It returns flag.ErrHelp as it is, so that asking for help exits cleanly.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		return err
+	}
+	return usageError(err)
+}
+
+// merge merges two images into a dual png.
+func merge(args []string) error {
+	fs := newFlagSet("merge", "[flags] img1 img2",
+		"Merges img1, shown without gamma correction, and img2, shown with it, into a dual png.\n"+
+			"img1 and img2 can be local files, web addresses or - for stdin. Missing images are replaced by plain white\n"+
+			"and black images. The merge command can be left out.")
+	f := newMergeFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 2 {
+		return usageError(errors.New("Expected at most two images, got " + strconv.Itoa(fs.NArg())))
+	}
+	return f.merge(fs.Arg(0), fs.Arg(1))
+}
+
+// solve prints the gAMA value and colour ranges the solver picks for two images.
+func solve(args []string) error {
+	fs := newFlagSet("solve", "[flags] img1 img2",
+		"Chooses the gAMA value and colour ranges that best show img1 without gamma correction and img2\n"+
+			"with it, and prints them as merge flags. The merge flags are accepted so that a merge command\n"+
+			"line can be reused, but only -display and -bg change the result.")
+	f := newMergeFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return usageError(errors.New("Expected two images"))
+	}
+
+	opts, err := f.options()
+	if err != nil {
+		return usageError(err)
+	}
+	bg, err := parseColor(*f.Background)
+	if err != nil {
+		return usageError(err)
+	}
+	img1, err := getImage(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	img2, err := getImage(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	s := dp.Solve(img1, img2, *f.Display, bg)
+	s.Apply(&opts)
+	log.Printf("gamma score %.2f, flat score %.2f", s.GammaScore, s.FlatScore)
+	fmt.Printf("-g %d -r1=%s -r2=%s\n", opts.Gamma, opts.Range1, opts.Range2)
+	return nil
+}
+
+// serve runs the web UI.
+func serve(args []string) error {
+	fs := newFlagSet("serve", "[flags]",
+		"Serves a web page for merging images with drag and drop on localhost.")
+	var (
+		port = fs.String("p", "8800", "Server port")
+		dir  = fs.String("d", "", "Asset directory. If none is given the embedded UI is served")
+	)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return usageError(errors.New("Unexpected argument: " + fs.Arg(0)))
+	}
+
+	srv := &webui.Server{Dir: *dir}
+	log.Println("Connect to http://localhost:" + *port + "/ in your browser")
+	return srv.ListenAndServe("127.0.0.1:" + *port)
+}
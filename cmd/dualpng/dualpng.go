@@ -15,75 +15,138 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	_ "image/gif"
 	_ "image/jpeg"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
 
-	"github.com/nfnt/resize"
-
 	dp "github.com/Necroforger/dualpng"
+	"github.com/Necroforger/dualpng/gamapng"
 )
 
-// Flags
-var (
-	Width      = flag.Uint("w", 0, "Width to resize both images to")
-	Height     = flag.Uint("h", 0, "Height to resize both images to")
-	Range1     = flag.String("r1", "0-230", "RGB Colour range for the first image")
-	Range2     = flag.String("r2", "230-255", "RGB Colour range for the second image")
-	Gama       = flag.Uint("g", 2300, "gAMA value")
-	OutputPath = flag.String("o", "", "Output file name")
-	MaskMatrix = flag.String("m", "", "Mask matrix to use for masking images. Ex [[1, 1],[1,0]] will create a checkerboard pattern")
-)
+// mergeFlags are the flags that configure merging two images.
+// Every row of a batch has its own set.
+type mergeFlags struct {
+	fs *flag.FlagSet
 
-func handle(err error) {
-	if err != nil {
-		log.Println(err)
-		os.Exit(1)
-	}
+	Width       *uint
+	Height      *uint
+	Range1      *string
+	Range2      *string
+	Gama        *uint
+	Brightness1 *float64
+	Brightness2 *float64
+	OutputPath  *string
+	MaskMatrix  *string
+	MaskImage   *string
+	Pattern     *string
+	Auto        *bool
+	Display     *float64
+	Background  *string
+	MergeMode   *string
+	Diffusion   *string
+	Background1 *string
+	Background2 *string
+	Compression *int
+	RowFilter   *string
+	Workers     *int
+	Interlace   *bool
+	ColorChunks *string
+	ICCProfile  *string
+	Palette     *int
+	Depth       *int
+	Gray        *bool
+	Stream      *bool
+	Parameters  *bool
+	From        *string
+	Preset      *string
+	Config      *string
 }
 
-func parseRange(txt string) (from int, to int, err error) {
-	numbers := strings.Split(txt, "-")
-	switch len(numbers) {
-	case 1:
-		to, err = strconv.Atoi(numbers[0])
-	case 2:
-		from, err = strconv.Atoi(numbers[0])
-		to, err = strconv.Atoi(numbers[1])
-	default:
-		err = errors.New("Invalid range")
-		return
-	}
-	return
+// newMergeFlags defines the merge flags on fs.
+func newMergeFlags(fs *flag.FlagSet) *mergeFlags {
+	return &mergeFlags{
+		fs:          fs,
+		Width:       fs.Uint("w", 0, "Width to resize both images to"),
+		Height:      fs.Uint("h", 0, "Height to resize both images to"),
+		Range1:      fs.String("r1", "0-230", "RGB Colour range for the first image. Ex 0-230, 0-230@0.5 or 0-230,0-220,0-200 for separate red, green and blue ranges"),
+		Range2:      fs.String("r2", "230-255", "RGB Colour range for the second image. Same form as -r1"),
+		Gama:        fs.Uint("g", 2300, "gAMA value"),
+		Brightness1: fs.Float64("b1", 1, "Brightness scale for the first image"),
+		Brightness2: fs.Float64("b2", 1, "Brightness scale for the second image"),
+		OutputPath:  fs.String("o", "", "Output file name, or - for stdout. Defaults to output.png"),
+		MaskMatrix:  fs.String("m", "", "Mask matrix to use for masking images. Ex [[1, 1],[1,0]] will create a checkerboard pattern"),
+		MaskImage:   fs.String("mi", "", "Grayscale image to use as a mask. White takes pixels from the first image, black from the second"),
+		Pattern:     fs.String("pattern", "", "Named mask pattern of the form name[:size[:density]], ex bayer:8:0.75. One of: "+strings.Join(dp.Patterns(), ", ")),
+		Auto:        fs.Bool("auto", false, "Automatically choose the gAMA value and colour ranges"),
+		Display:     fs.Float64("display", dp.DefaultDisplayGamma, "Display gamma targeted by -auto"),
+		Background:  fs.String("bg", "#ffffff", "Background colour targeted by -auto"),
+		MergeMode:   fs.String("mode", "gamma", "Merge mode: gamma, alpha or dither"),
+		Diffusion:   fs.String("diffusion", "floyd-steinberg", "Error diffusion used by dither mode: floyd-steinberg, atkinson or sierra"),
+		Background1: fs.String("bg1", "#ffffff", "Background colour the first image is shown on in alpha mode"),
+		Background2: fs.String("bg2", "#000000", "Background colour the second image is shown on in alpha mode"),
+		Compression: fs.Int("c", 0, "zlib compression level from 1 to 9. 0 uses the default level"),
+		RowFilter:   fs.String("filter", "adaptive", "PNG row filter: adaptive, none, sub, up, average, paeth or entropy"),
+		Workers:     fs.Int("j", 1, "Number of goroutines compressing the png. Faster for large images but slightly larger files"),
+		Interlace:   fs.Bool("interlace", false, "Write an Adam7 interlaced png"),
+		ColorChunks: fs.String("color", "", "Comma separated colour chunks to write in order: gAMA, sRGB, cHRM, iCCP or none. Ex sRGB,gAMA. Empty writes only gAMA"),
+		ICCProfile:  fs.String("icc", "", "ICC profile file written in the iCCP chunk"),
+		Palette:     fs.Int("palette", 0, "Reduce the output to a palette of at most this many colours, up to 256. 0 writes truecolour"),
+		Depth:       fs.Int("depth", 8, "Bit depth of processing and output: 8 or 16"),
+		Gray:        fs.Bool("gray", false, "Convert both images to grayscale and write a grayscale png"),
+		Stream:      fs.Bool("stream", false, "Merge the rows while they are written instead of merging the whole image first. Uses much less memory for huge images, gamma mode only"),
+		Parameters:  fs.Bool("params", true, "Embed the parameters in the output so that they can be reloaded with -from"),
+		From:        fs.String("from", "", "Dual png to reload the parameters from. Flags given on the command line override them"),
+		Preset:      fs.String("preset", "", "Named preset of merge flags. Flags given on the command line override it. See dualpng presets list"),
+		Config:      fs.String("config", "", "Config file with more presets"),
+	}
 }
 
-func getImage(path string) (image.Image, error) {
-	var (
-		source io.ReadCloser
-		err    error
-	)
-
+// openSource opens a local file or a web address for reading, or
+// standard input if path is "-". Errors are input errors.
+func openSource(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		if err := claimStdin(); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(os.Stdin), nil
+	}
 	if strings.HasPrefix(path, "http://") ||
 		strings.HasPrefix(path, "https://") {
 		resp, err := http.Get(path)
 		if err != nil {
-			return nil, err
+			return nil, inputError(err)
 		}
-		source = resp.Body
-	} else {
-		source, err = os.Open(path)
-		if err != nil {
-			return nil, err
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			resp.Body.Close()
+			return nil, inputError(errors.New(path + ": " + resp.Status))
 		}
+		return resp.Body, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, inputError(err)
+	}
+	return f, nil
+}
+
+func getImage(path string) (image.Image, error) {
+	source, err := openSource(path)
+	if err != nil {
+		return nil, err
 	}
 	defer source.Close()
 
 	img, _, err := image.Decode(source)
-	return img, err
+	if err != nil {
+		return nil, decodeError(errors.New(path + ": " + err.Error()))
+	}
+	return img, nil
 }
 
 func createUniformImage(clr color.Color, b image.Rectangle) *image.RGBA {
@@ -93,64 +156,231 @@ func createUniformImage(clr color.Color, b image.Rectangle) *image.RGBA {
 }
 
 func main() {
+	if err := run(os.Args[1:]); err != nil && err != flag.ErrHelp {
+		log.Println(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// options returns the options selected by the parsed flags.
+func (f *mergeFlags) options() (dp.Options, error) {
 	var (
-		img1, img2 image.Image
-		err        error
-		out        *os.File
-		mask       [][]float64
+		err  error
+		opts = dp.DefaultOptions()
 	)
 
-	flag.Parse()
+	if err := f.applyPreset(); err != nil {
+		return opts, err
+	}
+
+	// Flags that were explicitly set on the command line.
+	set := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	// Reload the parameters of an existing image
+	if *f.From != "" {
+		source, err := openSource(*f.From)
+		if err != nil {
+			return opts, err
+		}
+		opts, err = dp.ReadOptions(source)
+		source.Close()
+		if err != nil {
+			return opts, decodeError(err)
+		}
+	}
+
+	// given reports whether a flag should be applied to the options.
+	// Defaults are only applied when the options were not reloaded.
+	given := func(name string) bool { return *f.From == "" || set[name] }
+
+	if given("mode") {
+		mode, err := dp.ParseMode(*f.MergeMode)
+		if err != nil {
+			return opts, err
+		}
+		if mode == dp.ModeAlpha && *f.From == "" {
+			opts = dp.DefaultAlphaOptions()
+		}
+		opts.Mode = mode
+	}
+	if opts.Mode == dp.ModeAlpha {
+		if given("bg1") {
+			if opts.Background1, err = parseColor(*f.Background1); err != nil {
+				return opts, err
+			}
+		}
+		if given("bg2") {
+			if opts.Background2, err = parseColor(*f.Background2); err != nil {
+				return opts, err
+			}
+		}
+	}
+	if opts.Mode == dp.ModeDither && given("diffusion") {
+		if opts.Diffusion, err = dp.ParseDiffusion(*f.Diffusion); err != nil {
+			return opts, err
+		}
+	}
+
+	if given("w") {
+		opts.Width = *f.Width
+	}
+	if given("h") {
+		opts.Height = *f.Height
+	}
+	if given("b1") {
+		opts.Brightness1 = *f.Brightness1
+	}
+	if given("b2") {
+		opts.Brightness2 = *f.Brightness2
+	}
+	if set["g"] || *f.From == "" && opts.Mode != dp.ModeAlpha {
+		opts.Gamma = uint32(*f.Gama)
+	}
+
+	// Compression settings
+	if given("c") {
+		opts.Encoder.CompressionLevel = gamapng.CompressionLevel(*f.Compression)
+	}
+	if given("filter") {
+		if opts.Encoder.Filter, err = gamapng.ParseFilterStrategy(*f.RowFilter); err != nil {
+			return opts, err
+		}
+	}
+	if given("j") {
+		opts.Encoder.Workers = *f.Workers
+	}
+	if given("interlace") {
+		opts.Encoder.Interlace = *f.Interlace
+	}
+	if given("palette") {
+		opts.Palette = *f.Palette
+	}
+	if given("depth") {
+		opts.Depth = *f.Depth
+	}
+	if given("gray") {
+		opts.Gray = *f.Gray
+	}
+	if given("color") {
+		if opts.ColorChunks, err = dp.ParseColorChunks(*f.ColorChunks); err != nil {
+			return opts, err
+		}
+	}
+	if given("params") {
+		opts.EmbedParameters = *f.Parameters
+	}
+	if *f.ICCProfile != "" {
+		profile, err := ioutil.ReadFile(*f.ICCProfile)
+		if err != nil {
+			return opts, inputError(err)
+		}
+		name := strings.TrimSuffix(filepath.Base(*f.ICCProfile), filepath.Ext(*f.ICCProfile))
+		opts.ICCProfile = &gamapng.ICCProfile{Name: name, Profile: profile}
+	}
 
 	// Obtain colour ranges
-	r1From, r1To, err := parseRange(*Range1)
-	handle(err)
-	r2From, r2To, err := parseRange(*Range2)
-	handle(err)
+	if set["r1"] || *f.From == "" && opts.Mode != dp.ModeAlpha {
+		if opts.Range1, err = dp.ParseLevels(*f.Range1); err != nil {
+			return opts, err
+		}
+	}
+	if set["r2"] || *f.From == "" && opts.Mode != dp.ModeAlpha {
+		if opts.Range2, err = dp.ParseLevels(*f.Range2); err != nil {
+			return opts, err
+		}
+	}
 
 	// Parse mask
-	if *MaskMatrix != "" {
-		err = json.Unmarshal([]byte(*MaskMatrix), &mask)
+	if set["m"] && set["mi"] || set["m"] && set["pattern"] || set["mi"] && set["pattern"] {
+		return opts, errors.New("Only one of -m, -mi and -pattern can be used")
+	}
+	if *f.MaskMatrix != "" {
+		if err = json.Unmarshal([]byte(*f.MaskMatrix), &opts.Mask); err != nil {
+			return opts, errors.New("Error parsing mask: " + err.Error())
+		}
+	}
+	if *f.MaskImage != "" {
+		source, err := openSource(*f.MaskImage)
 		if err != nil {
-			log.Println("Error parsing mask : ", err)
-			return
+			return opts, err
 		}
+		opts.Mask, err = dp.LoadMask(source)
+		source.Close()
+		if err != nil {
+			return opts, decodeError(err)
+		}
+	}
+	if *f.Pattern != "" {
+		if opts.Mask, err = dp.ParsePattern(*f.Pattern); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// merge merges the images at path1 and path2 into the output file
+// according to the parsed flags. If a path is empty a uniformly
+// coloured image is used instead.
+func (f *mergeFlags) merge(path1, path2 string) error {
+	var (
+		img1, img2 image.Image
+		err        error
+	)
+
+	opts, err := f.options()
+	if err != nil {
+		return usageError(err)
+	}
+	if path1 == "-" && path2 == "-" {
+		return usageError(errors.New("Only one input can be read from stdin"))
 	}
 
 	// Decode images
 	// If no image path is provided use a uniformly coloured background.
-	if len(flag.Args()) > 0 {
-		img1, err = getImage(flag.Arg(0))
+	if path1 != "" {
+		if img1, err = getImage(path1); err != nil {
+			return err
+		}
 	} else {
 		img1 = createUniformImage(color.White, image.Rect(0, 0, 500, 500))
 	}
-	if len(flag.Args()) > 1 {
-		img2, err = getImage(flag.Arg(1))
+	if path2 != "" {
+		if img2, err = getImage(path2); err != nil {
+			return err
+		}
 	} else {
 		img2 = createUniformImage(color.Black, img1.Bounds())
 	}
-	handle(err)
+
+	// Let the solver pick the gAMA value and colour ranges
+	if *f.Auto {
+		bg, err := parseColor(*f.Background)
+		if err != nil {
+			return usageError(err)
+		}
+		s := dp.Solve(img1, img2, *f.Display, bg)
+		s.Apply(&opts)
+		log.Printf("auto: -g %d -r1=%s -r2=%s (gamma score %.2f, flat score %.2f)",
+			opts.Gamma, opts.Range1, opts.Range2, s.GammaScore, s.FlatScore)
+	}
+
+	var img image.Image
+	if *f.Stream {
+		img, err = dp.NewMergeSource(img1, img2, opts)
+	} else {
+		img, err = dp.Build(img1, img2, opts)
+	}
+	if err != nil {
+		return usageError(err)
+	}
 
 	// Set output destination
-	if *OutputPath == "" {
-		*OutputPath = "output.png"
-	}
-	out, err = os.Create(*OutputPath)
-	handle(err)
-	defer out.Close()
-
-	if *Width > 0 || *Height > 0 {
-		img2 = resize.Resize(*Width, *Height, img2, resize.Lanczos3)
-		img1 = resize.Resize(*Width, *Height, img1, resize.Lanczos3)
-	}
-
-	dp.Encode(
-		out,
-		dp.MergeImages(
-			dp.LevelImage(img1, uint8(r1From), uint8(r1To)),
-			dp.LevelImage(img2, uint8(r2From), uint8(r2To)),
-			mask,
-		),
-		uint32(*Gama),
-	)
+	path := *f.OutputPath
+	if path == "" {
+		path = "output.png"
+	}
+	return writeFile(path, func(w io.Writer) error {
+		return opts.Encode(w, img)
+	})
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testImg1 = "images/ika_musume.jpeg"
+	testImg2 = "images/hakase_trumpet.png"
+)
+
+func TestExitCodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dualpng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	garbage := filepath.Join(dir, "garbage.png")
+	if err := ioutil.WriteFile(garbage, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "output.png")
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"merge", []string{"-w", "20", "-o", out, testImg1, testImg2}, 0},
+		{"merge command", []string{"merge", "-w", "20", "-o", out, testImg1, testImg2}, 0},
+		{"help", []string{"help"}, 0},
+		{"help flag", []string{"merge", "-help"}, 0},
+		{"bad r1", []string{"-r1", "abc", "-o", out, testImg1, testImg2}, exitUsage},
+		{"bad mode", []string{"-mode", "sepia", "-o", out, testImg1, testImg2}, exitUsage},
+		{"unknown flag", []string{"-frobnicate", testImg1, testImg2}, exitUsage},
+		{"too many images", []string{"-o", out, testImg1, testImg2, testImg1}, exitUsage},
+		{"unknown help topic", []string{"help", "frobnicate"}, exitUsage},
+		{"missing input", []string{"-o", out, filepath.Join(dir, "missing.png"), testImg2}, exitInput},
+		{"missing mask", []string{"-mi", filepath.Join(dir, "missing.png"), "-o", out, testImg1, testImg2}, exitInput},
+		{"missing inspect input", []string{"inspect", filepath.Join(dir, "missing.png")}, exitInput},
+		{"undecodable input", []string{"-o", out, testImg1, garbage}, exitDecode},
+		{"undecodable preview input", []string{"preview", garbage}, exitDecode},
+		{"inspect without parameters", []string{"inspect", "-params", testImg2}, exitDecode},
+		{"unwritable output", []string{"-w", "20", "-o", filepath.Join(dir, "missing", "out.png"), testImg1, testImg2}, exitEncode},
+		{"output is a directory", []string{"-w", "20", "-o", dir, testImg1, testImg2}, exitEncode},
+		{"zero gAMA", []string{"-w", "20", "-g", "0", "-o", out, testImg1, testImg2}, exitUsage},
+		{"zero gAMA with gAMA chunk", []string{"-w", "20", "-g", "0", "-color", "sRGB,gAMA", "-o", out, testImg1, testImg2}, exitUsage},
+		{"zero gAMA without gAMA chunk", []string{"-w", "20", "-g", "0", "-color", "none", "-o", out, testImg1, testImg2}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := 0
+			if err := run(tt.args); err != nil && err != flag.ErrHelp {
+				code = exitCode(err)
+			}
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestFailedWriteKeepsOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dualpng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "output.png")
+	old := []byte("previous output")
+	if err := ioutil.WriteFile(out, old, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The encoder fails after more than a buffer of data has reached the
+	// temporary file.
+	err = writeFile(out, func(w io.Writer) error {
+		if _, err := w.Write(make([]byte, 1<<16)); err != nil {
+			return err
+		}
+		return errors.New("encoder failed")
+	})
+	if code := exitCode(err); err == nil || code != exitEncode {
+		t.Fatalf("exit code %d (%v), want %d", code, err, exitEncode)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(old) {
+		t.Errorf("output changed to %d bytes", len(b))
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), ".output.png.") {
+			t.Errorf("temporary file %s left behind", f.Name())
+		}
+	}
+	if len(files) != 1 {
+		t.Errorf("%d files in the output directory, want 1", len(files))
+	}
+}
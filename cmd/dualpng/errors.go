@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Exit codes
+const (
+	exitFailure = 1 // Any other failure, like failed rows of a batch
+	exitUsage   = 2 // Invalid flags, arguments or parameters
+	exitInput   = 3 // An input could not be opened or read
+	exitDecode  = 4 // An input could not be decoded
+	exitEncode  = 5 // The output could not be encoded or written
+)
+
+// exitError is an error that makes the command exit with its code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+// withCode wraps err so that the command exits with code. A nil err
+// stays nil, and errors that already have a code keep it.
+func withCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*exitError); ok {
+		return err
+	}
+	return &exitError{code, err}
+}
+
+func usageError(err error) error  { return withCode(exitUsage, err) }
+func inputError(err error) error  { return withCode(exitInput, err) }
+func decodeError(err error) error { return withCode(exitDecode, err) }
+func encodeError(err error) error { return withCode(exitEncode, err) }
+
+// exitCode returns the code the command exits with after err.
+func exitCode(err error) int {
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+	return exitFailure
+}
+
+// writeFile writes the file at path with write, or standard output if
+// path is "-". The data goes to a temporary file in the same directory
+// that only replaces path once it is complete, so a failure never leaves a
+// truncated file behind. Errors are encode errors.
+func writeFile(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		if err := claimStdout(); err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(os.Stdout)
+		err := write(bw)
+		if err == nil {
+			err = bw.Flush()
+		}
+		return encodeError(err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return encodeError(err)
+	}
+	tmp := f.Name()
+	bw := bufio.NewWriter(f)
+	err = write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return encodeError(err)
+	}
+	return nil
+}
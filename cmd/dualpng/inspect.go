@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	dp "github.com/Necroforger/dualpng"
+	"github.com/Necroforger/dualpng/gamapng"
+)
+
+// colorTypes names the PNG colour types.
+var colorTypes = map[uint8]string{
+	0: "grayscale",
+	2: "truecolour",
+	3: "indexed",
+	4: "grayscale alpha",
+	6: "truecolour alpha",
+}
+
+// inspect prints the chunks, gAMA value and parameters of a png.
+func inspect(args []string) error {
+	fs := newFlagSet("inspect", "[flags] image.png",
+		"Prints the size, colour type and gAMA value of a png, lists its chunks and prints the\n"+
+			"parameters a dual png was made with.")
+	params := fs.Bool("params", false, "Only print the embedded parameters as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return usageError(errors.New("Expected one image"))
+	}
+
+	source, err := openSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(source)
+	source.Close()
+	if err != nil {
+		return inputError(err)
+	}
+
+	opts, perr := dp.ReadOptions(bytes.NewReader(data))
+	if *params {
+		if perr != nil {
+			return decodeError(perr)
+		}
+		return printJSON(os.Stdout, opts)
+	}
+
+	chunks, err := gamapng.ReadChunks(bytes.NewReader(data))
+	if err != nil {
+		return decodeError(errors.New(fs.Arg(0) + ": " + err.Error()))
+	}
+	printChunks(os.Stdout, chunks)
+	fmt.Println()
+	if perr != nil {
+		fmt.Println("Parameters: none")
+		return nil
+	}
+	fmt.Println("Parameters:")
+	return printJSON(os.Stdout, opts)
+}
+
+// printChunks prints the header and gAMA value held by chunks, followed
+// by a line for each chunk.
+func printChunks(w io.Writer, chunks []gamapng.Chunk) {
+	for _, c := range chunks {
+		switch {
+		case c.Type == "IHDR" && len(c.Data) == 13:
+			interlace := "none"
+			if c.Data[12] == 1 {
+				interlace = "Adam7"
+			}
+			colorType, ok := colorTypes[c.Data[9]]
+			if !ok {
+				colorType = fmt.Sprint("unknown (", c.Data[9], ")")
+			}
+			fmt.Fprintf(w, "Size:       %dx%d\n", binary.BigEndian.Uint32(c.Data[0:4]), binary.BigEndian.Uint32(c.Data[4:8]))
+			fmt.Fprintf(w, "Bit depth:  %d\n", c.Data[8])
+			fmt.Fprintf(w, "Colour:     %s\n", colorType)
+			fmt.Fprintf(w, "Interlace:  %s\n", interlace)
+		case c.Type == "gAMA" && len(c.Data) == 4:
+			g := binary.BigEndian.Uint32(c.Data)
+			fmt.Fprintf(w, "gAMA:       %d (%.5f)\n", g, float64(g)/100000)
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Chunks:")
+	idat, size := 0, 0
+	for i, c := range chunks {
+		// Collapse runs of IDAT chunks into a single line.
+		if c.Type == "IDAT" {
+			idat++
+			size += len(c.Data)
+			if i+1 < len(chunks) && chunks[i+1].Type == "IDAT" {
+				continue
+			}
+			fmt.Fprintf(w, "  IDAT %10d bytes in %d chunks\n", size, idat)
+			idat, size = 0, 0
+			continue
+		}
+		fmt.Fprintf(w, "  %s %10d bytes", c.Type, len(c.Data))
+		switch c.Type {
+		case "tEXt", "zTXt", "iTXt", "iCCP":
+			// The keyword or profile name ends at the first null byte.
+			if n := bytes.IndexByte(c.Data, 0); n > 0 {
+				fmt.Fprintf(w, "  %s", c.Data[:n])
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
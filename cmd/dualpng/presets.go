@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// flagValues maps flag names to their values. In JSON a value can be of
+// any type, so that {"w": 1024, "gray": true, "m": [[1,0],[0,1]]} holds
+// the values "1024", "true" and "[[1,0],[0,1]]".
+type flagValues map[string]string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (v *flagValues) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*v = nil
+	if len(raw) > 0 {
+		*v = flagValues{}
+	}
+	for name, r := range raw {
+		var s string
+		if err := json.Unmarshal(r, &s); err == nil {
+			(*v)[name] = s
+		} else {
+			(*v)[name] = string(r)
+		}
+	}
+	return nil
+}
+
+// args returns the values as command line arguments sorted by name.
+func (v flagValues) args() []string {
+	var args []string
+	for name, value := range v {
+		args = append(args, "-"+name+"="+value)
+	}
+	sort.Strings(args)
+	return args
+}
+
+// A preset is a named set of merge flags.
+type preset struct {
+	Description string     `json:"description"`
+	Flags       flagValues `json:"flags"`
+
+	source string // Where the preset was loaded from
+}
+
+// config is the content of a config file.
+type config struct {
+	Presets map[string]preset `json:"presets"`
+}
+
+// builtinPresets are the presets that need no config file.
+var builtinPresets = map[string]preset{
+	"discord-dark": {
+		Description: "Dithered for Discord's dark theme, which ignores gAMA and downscales images",
+		Flags:       flagValues{"mode": "dither", "auto": "true", "bg": "#36393f"},
+	},
+	"twitter-light": {
+		Description: "Twitter's light theme. The palette keeps the file small, as Twitter may convert large pngs to JPEG",
+		Flags:       flagValues{"auto": "true", "bg": "#ffffff", "palette": "256"},
+	},
+	"thread": {
+		Description: "The thread mask example of the README",
+		Flags: flagValues{
+			"r1": "230", "r2": "230-255", "g": "1300", "w": "1500",
+			"m": "[[0,1,0,1,1],[1,0,1,1,1],[1,1,1,1,0],[1,1,1,0,1],[1,1,1,0,1],[1,1,0,1,0]]",
+		},
+	},
+}
+
+// userConfigPath returns the path of the config file in the user's config
+// directory, or an empty string if there is no such directory.
+func userConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dualpng", "config.json")
+}
+
+// loadPresets returns the built in presets, followed by those of the
+// config file in the user's config directory and those of the config file
+// at path. Presets loaded later replace presets with the same name.
+// A missing config file in the user's config directory is ignored.
+//    path : path of a config file, or an empty string for none
+func loadPresets(path string) (map[string]preset, error) {
+	presets := map[string]preset{}
+	for name, p := range builtinPresets {
+		p.source = "built in"
+		presets[name] = p
+	}
+
+	if user := userConfigPath(); user != "" {
+		if _, err := os.Stat(user); err == nil {
+			if err := readConfig(user, presets); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if path != "" {
+		if err := readConfig(path, presets); err != nil {
+			return nil, err
+		}
+	}
+	return presets, nil
+}
+
+// readConfig adds the presets of the config file at path to presets.
+func readConfig(path string, presets map[string]preset) error {
+	source, err := openSource(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	var c config
+	if err := json.NewDecoder(source).Decode(&c); err != nil {
+		return decodeError(errors.New("Invalid config " + path + ": " + err.Error()))
+	}
+	for name, p := range c.Presets {
+		for flagName := range p.Flags {
+			switch flagName {
+			case "preset", "config", "o":
+				return usageError(errors.New("Preset " + name + " in " + path + " can not set -" + flagName))
+			}
+		}
+		p.source = path
+		presets[name] = p
+	}
+	return nil
+}
+
+// applyPreset sets the flags of the preset chosen with -preset that were
+// not given on the command line. A mask given on the command line replaces
+// the mask of the preset.
+func (f *mergeFlags) applyPreset() error {
+	if *f.Preset == "" {
+		return nil
+	}
+	presets, err := loadPresets(*f.Config)
+	if err != nil {
+		return err
+	}
+	p, ok := presets[*f.Preset]
+	if !ok {
+		return usageError(errors.New("Unknown preset: " + *f.Preset))
+	}
+
+	set := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	masked := set["m"] || set["mi"] || set["pattern"]
+	for name, v := range p.Flags {
+		if set[name] || masked && (name == "m" || name == "mi" || name == "pattern") {
+			continue
+		}
+		if err := f.fs.Set(name, v); err != nil {
+			return usageError(errors.New("Preset " + *f.Preset + ": -" + name + ": " + err.Error()))
+		}
+	}
+	return nil
+}
+
+// presets lists the presets, or shows the flags of one of them.
+func presets(args []string) error {
+	fs := newFlagSet("presets", "[flags] list | show name",
+		"Lists the presets, or shows the flags set by one of them. Presets are built in, or read from\n"+
+			"dualpng/config.json in the user config directory and from the file given with -config, where\n"+
+			"later presets replace earlier ones with the same name. Use a preset with -preset name.")
+	configPath := fs.String("config", "", "Config file with more presets")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	all, err := loadPresets(*configPath)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fs.NArg() == 0 || fs.NArg() == 1 && fs.Arg(0) == "list":
+		var names []string
+		for name := range all {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-16s %s\n", name, all[name].Description)
+		}
+		return nil
+	case fs.NArg() == 2 && fs.Arg(0) == "show":
+		p, ok := all[fs.Arg(1)]
+		if !ok {
+			return usageError(errors.New("Unknown preset: " + fs.Arg(1)))
+		}
+		fmt.Printf("%s: %s\n", fs.Arg(1), p.Description)
+		fmt.Printf("Source: %s\n", p.source)
+		fmt.Printf("Flags:  %s\n", strings.Join(p.Flags.args(), " "))
+		return nil
+	}
+	fs.Usage()
+	return usageError(errors.New("Expected list or show name"))
+}
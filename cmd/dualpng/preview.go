@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	dp "github.com/Necroforger/dualpng"
+)
+
+// parseColor parses a hex colour of the form "#rrggbb" or "#rrggbbaa".
+func parseColor(txt string) (color.RGBA, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(txt, "#"))
+	if err != nil || (len(b) != 3 && len(b) != 4) {
+		return color.RGBA{}, errors.New("Invalid colour: " + txt)
+	}
+	c := color.RGBA{b[0], b[1], b[2], 0xff}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+	return c, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	return writeFile(path, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
+}
+
+// preview renders how a dual png appears with and without gamma correction.
+func preview(args []string) error {
+	fs := newFlagSet("preview", "[flags] image.png",
+		"Renders how a dual png appears in a viewer that applies the gAMA chunk, and in one that ignores\n"+
+			"it and shows the raw pixels on a background colour.")
+	var (
+		display    = fs.Float64("display", dp.DefaultDisplayGamma, "Display gamma of the gamma correcting viewer")
+		background = fs.String("bg", "#ffffff", "Background colour of the viewer that ignores gamma")
+		gammaPath  = fs.String("og", "preview_gamma.png", "Output path of the gamma corrected render, or - for stdout")
+		flatPath   = fs.String("of", "preview_flat.png", "Output path of the render without gamma, or - for stdout")
+	)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return usageError(errors.New("Expected one image"))
+	}
+
+	if *gammaPath == "-" && *flatPath == "-" {
+		return usageError(errors.New("Only one of -og and -of can be written to stdout"))
+	}
+
+	bg, err := parseColor(*background)
+	if err != nil {
+		return usageError(err)
+	}
+
+	source, err := openSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	gamma, flat, err := dp.Preview(source, *display, bg)
+	if err != nil {
+		return decodeError(err)
+	}
+	if err := writePNG(*gammaPath, gamma); err != nil {
+		return err
+	}
+	return writePNG(*flatPath, flat)
+}
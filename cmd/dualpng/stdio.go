@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// stdio records whether standard input has been read and standard output
+// written, so that two paths given as "-" fail instead of sharing a stream.
+var stdio struct {
+	sync.Mutex
+	in, out bool
+}
+
+// claimStdin reserves standard input for the caller. Errors are usage errors.
+func claimStdin() error {
+	stdio.Lock()
+	defer stdio.Unlock()
+	if stdio.in {
+		return usageError(errors.New("Only one input can be read from stdin"))
+	}
+	stdio.in = true
+	return nil
+}
+
+// claimStdout reserves standard output for the caller. Errors are usage errors.
+func claimStdout() error {
+	stdio.Lock()
+	defer stdio.Unlock()
+	if stdio.out {
+		return usageError(errors.New("Only one output can be written to stdout"))
+	}
+	stdio.out = true
+	return nil
+}
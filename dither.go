@@ -0,0 +1,180 @@
+package dualpng
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+// Diffusion is an error diffusion algorithm used by MergeDiffused.
+type Diffusion string
+
+// Error diffusion algorithms
+const (
+	FloydSteinberg Diffusion = "floyd-steinberg"
+	Atkinson       Diffusion = "atkinson"
+	Sierra         Diffusion = "sierra"
+)
+
+// diffusionWeight is the share of the error passed to the pixel dx, dy away.
+type diffusionWeight struct {
+	dx, dy int
+	weight float64
+}
+
+var diffusionKernels = map[Diffusion][]diffusionWeight{
+	FloydSteinberg: {
+		{1, 0, 7.0 / 16}, {-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+	},
+	// Atkinson only passes on three quarters of the error.
+	Atkinson: {
+		{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8},
+		{-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8},
+		{0, 2, 1.0 / 8},
+	},
+	Sierra: {
+		{1, 0, 5.0 / 32}, {2, 0, 3.0 / 32},
+		{-2, 1, 2.0 / 32}, {-1, 1, 4.0 / 32}, {0, 1, 5.0 / 32}, {1, 1, 4.0 / 32}, {2, 1, 2.0 / 32},
+		{-1, 2, 2.0 / 32}, {0, 2, 3.0 / 32}, {1, 2, 2.0 / 32},
+	},
+}
+
+// ParseDiffusion parses the name of an error diffusion algorithm.
+func ParseDiffusion(txt string) (Diffusion, error) {
+	d := Diffusion(txt)
+	if _, ok := diffusionKernels[d]; !ok {
+		return "", errors.New("Invalid diffusion: " + txt)
+	}
+	return d, nil
+}
+
+// MergeDiffused merges two images by choosing every pixel from one of them
+// with error diffusion, which avoids the moiré of repeating masks when the
+// result is downscaled. Pixels are taken from img1 at the given density,
+// shifted towards whichever image has more local contrast so that the
+// edges of both images are kept.
+//     img1    : first image
+//     img2    : second image
+//     d       : error diffusion algorithm
+//     density : fraction of pixels between 0 and 1 to take from img1
+func MergeDiffused(img1, img2 image.Image, d Diffusion, density float64) (*image.RGBA, error) {
+	b := img1.Bounds().Union(img2.Bounds())
+	b = b.Sub(b.Min)
+	combined := image.NewRGBA(b)
+	err := diffuse(img1, img2, d, density, b.Dx(), b.Dy(), func(x, y int, src image.Image) {
+		r, g, bl, a := src.At(x, y).RGBA()
+		o := combined.PixOffset(x, y)
+		combined.Pix[o+0] = uint8(r >> 8)
+		combined.Pix[o+1] = uint8(g >> 8)
+		combined.Pix[o+2] = uint8(bl >> 8)
+		combined.Pix[o+3] = uint8(a >> 8)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return combined, nil
+}
+
+// MergeDiffused16 is MergeDiffused for the 16 bit pipeline.
+//     img1    : first image
+//     img2    : second image
+//     d       : error diffusion algorithm
+//     density : fraction of pixels between 0 and 1 to take from img1
+func MergeDiffused16(img1, img2 image.Image, d Diffusion, density float64) (*image.RGBA64, error) {
+	b := img1.Bounds().Union(img2.Bounds())
+	b = b.Sub(b.Min)
+	combined := image.NewRGBA64(b)
+	err := diffuse(img1, img2, d, density, b.Dx(), b.Dy(), func(x, y int, src image.Image) {
+		combined.Set(x, y, src.At(x, y))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return combined, nil
+}
+
+// diffuse chooses which image every pixel of a w by h image is taken from
+// for MergeDiffused and passes the pixel and its source to set.
+func diffuse(img1, img2 image.Image, d Diffusion, density float64, w, h int, set func(x, y int, src image.Image)) error {
+	kernel, ok := diffusionKernels[d]
+	if !ok {
+		return errors.New("Invalid diffusion: " + string(d))
+	}
+
+	c1 := localContrast(img1, w, h)
+	c2 := localContrast(img2, w, h)
+	errs := make([]float64, w*h)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			v := clamp(density+(c1[i]-c2[i])/4, 0, 1) + errs[i]
+
+			src := img2
+			if v >= 0.5 {
+				src = img1
+				v--
+			}
+			set(x, y, src)
+
+			for _, k := range kernel {
+				nx, ny := x+k.dx, y+k.dy
+				if nx >= 0 && nx < w && ny < h {
+					errs[ny*w+nx] += v * k.weight
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// localContrast returns how far the luminance of every pixel of img is from
+// the mean of its 3x3 neighbourhood, scaled so the largest value is 1.
+func localContrast(img image.Image, w, h int) []float64 {
+	lum := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			lum[y*w+x] = luminance(r, g, b) / 0xffff
+		}
+	}
+
+	contrast := make([]float64, w*h)
+	max := 0.0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum, n := 0.0, 0
+			for ny := y - 1; ny <= y+1; ny++ {
+				for nx := x - 1; nx <= x+1; nx++ {
+					if nx >= 0 && nx < w && ny >= 0 && ny < h {
+						sum += lum[ny*w+nx]
+						n++
+					}
+				}
+			}
+			c := math.Abs(lum[y*w+x] - sum/float64(n))
+			contrast[y*w+x] = c
+			if c > max {
+				max = c
+			}
+		}
+	}
+	if max > 0 {
+		for i := range contrast {
+			contrast[i] /= max
+		}
+	}
+	return contrast
+}
+
+// maskDensity returns the mean weight of a mask matrix.
+func maskDensity(m [][]float64) float64 {
+	sum, n := 0.0, 0
+	for _, row := range m {
+		for _, v := range row {
+			sum += clamp(v, 0, 1)
+			n++
+		}
+	}
+	return sum / float64(n)
+}
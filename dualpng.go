@@ -3,7 +3,6 @@ package dualpng
 import (
 	"image"
 	"image/color"
-	"image/draw"
 	"io"
 
 	"github.com/Necroforger/dualpng/gamapng"
@@ -11,30 +10,83 @@ import (
 
 // CreateMask creates a mask from the given matrix repeated over
 // bounds b.
-//    m : Mask matrix. Values greater than 0 will be opaque.
-//           Values lower than zero will be transparent.
+//    m : Mask matrix. Values are weights between 0 and 1, where 1 is
+//           opaque and 0 is transparent. Values outside of that range
+//           are clamped.
 //    b : Bounds of the mask.
 func CreateMask(m [][]float64, b image.Rectangle) *image.RGBA {
 	mask := image.NewRGBA(b)
-	w := b.Dx()
+	t := newTiledMask(m)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			mask.Pix[mask.PixOffset(x, y)+3] = t.weight(x-b.Min.X, y-b.Min.Y)
+		}
+	}
+	return mask
+}
 
-	for y := b.Min.Y; y < b.Max.Y; y += len(m) {
-		for x := b.Min.X; x < b.Max.X; x += len(m[0]) {
+// tiledMask holds the weights of a mask matrix between 0 and 255.
+// The matrix is repeated over the whole plane starting at the origin,
+// so the weight of any pixel is computed without a mask image.
+type tiledMask [][]uint8
 
-			// Draw pattern
-			for i := 0; i < len(m) && i+y < b.Max.Y; i++ {
-				for j := 0; j < len(m[0]) && x+j < b.Max.X; j++ {
-					mask.Pix[(y+i)*w*4+(x+j)*4+3] = uint8(m[i][j] * 255)
-				}
-			}
+func newTiledMask(m [][]float64) tiledMask {
+	t := make(tiledMask, len(m))
+	for i, row := range m {
+		t[i] = make([]uint8, len(row))
+		for j, v := range row {
+			t[i][j] = uint8(clamp(v, 0, 1)*255 + 0.5)
+		}
+	}
+	return t
+}
+
+// weight returns the weight of the pixel at x, y.
+func (t tiledMask) weight(x, y int) uint8 {
+	row := t[mod(y, len(t))]
+	return row[mod(x, len(row))]
+}
+
+// mod returns a modulo n, which is never negative.
+func mod(a, n int) int {
+	if a %= n; a < 0 {
+		a += n
+	}
+	return a
+}
 
+// LoadMask reads a mask matrix from a grayscale image.
+// White pixels have a weight of 1 and black pixels a weight of 0.
+//    r : source of the encoded image
+func LoadMask(r io.Reader) ([][]float64, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	m := make([][]float64, b.Dy())
+	for y := range m {
+		m[y] = make([]float64, b.Dx())
+		for x := range m[y] {
+			g := color.Gray16Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray16)
+			m[y][x] = float64(g.Y) / 0xffff
 		}
 	}
-	return mask
+	return m, nil
+}
+
+// DefaultMask is the mask used when merging without a mask matrix.
+// Three out of every four pixels are taken from the first image.
+var DefaultMask = [][]float64{
+	{1, 1},
+	{1, 0},
 }
 
 // MergeImages merges two images with the given maskmatrix.
-// If maskmatrix is nil, it will merge by alternating every other pixel
+// Every pixel is a blend of the two images weighted by the mask,
+// a weight of 1 takes the pixel from img1 and 0 takes it from img2.
+// If maskmatrix is nil, DefaultMask is used, which takes three
+// out of every four pixels from img1.
 //     img1       : first image
 //     img2       : Second image
 //     maskmatrix : Mask to use when merging two images together.
@@ -56,49 +108,100 @@ func MergeImages(img1, img2 image.Image, maskmatrix [][]float64) *image.RGBA {
 	combined := image.NewRGBA(image.Rect(0, 0, maxWidth, maxHeight))
 
 	if maskmatrix == nil {
-		// Alternate filling pixels in a checkerboard pattern.
-		b = combined.Bounds()
-		for y := b.Min.Y; y < b.Max.Y; y++ {
-			for x := b.Min.X; x < b.Max.X; x++ {
-				if y%2 == 0 || x%2 == 0 {
-					combined.Set(x, y, img1.At(x, y))
-				} else {
-					combined.Set(x, y, img2.At(x, y))
-				}
-			}
+		maskmatrix = DefaultMask
+	}
+	mask := newTiledMask(maskmatrix)
+
+	b = combined.Bounds()
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		row1 := make([]uint16, 4*b.Dx())
+		row2 := make([]uint16, 4*b.Dx())
+		for y := y0; y < y1; y++ {
+			readRow(img1, y, b.Min.X, b.Max.X, row1)
+			readRow(img2, y, b.Min.X, b.Max.X, row2)
+			o := combined.PixOffset(b.Min.X, y)
+			blendRow(combined.Pix[o:o+len(row1)], row1, row2, mask, b.Min.X, y)
 		}
-	} else {
-		// Draw img1 over img2 with mask.
-		mask := CreateMask(maskmatrix, combined.Bounds())
-		draw.Draw(combined, img2.Bounds(), img2, image.ZP, draw.Src)
-		draw.DrawMask(combined, mask.Bounds(), img1, image.ZP, mask, image.ZP, draw.Over)
+	})
+
+	return combined
+}
+
+func clamp(v, low, high float64) float64 {
+	if v < low {
+		return low
 	}
+	if v > high {
+		return high
+	}
+	return v
+}
 
+// blendRow blends rows of two images read by readRow, starting at x on
+// row y, into the 8 bit values of dst weighted by the mask.
+func blendRow(dst []uint8, row1, row2 []uint16, mask tiledMask, x, y int) {
+	blend := func(c1, c2 uint16, w uint32) uint8 {
+		return uint8((uint32(c1)*w + uint32(c2)*(255-w)) / 255 >> 8)
+	}
+	for i := 0; i < len(dst); i, x = i+4, x+1 {
+		w := uint32(mask.weight(x, y))
+		dst[i+0] = blend(row1[i+0], row2[i+0], w)
+		dst[i+1] = blend(row1[i+1], row2[i+1], w)
+		dst[i+2] = blend(row1[i+2], row2[i+2], w)
+		dst[i+3] = blend(row1[i+3], row2[i+3], w)
+	}
+}
+
+// MergeImages16 is MergeImages for the 16 bit pipeline.
+//     img1       : first image
+//     img2       : Second image
+//     maskmatrix : Mask to use when merging two images together.
+func MergeImages16(img1, img2 image.Image, maskmatrix [][]float64) *image.RGBA64 {
+	b := img1.Bounds()
+	b2 := img2.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if b2.Dx() > w {
+		w = b2.Dx()
+	}
+	if b2.Dy() > h {
+		h = b2.Dy()
+	}
+	combined := image.NewRGBA64(image.Rect(0, 0, w, h))
+
+	if maskmatrix == nil {
+		maskmatrix = DefaultMask
+	}
+	mask := newTiledMask(maskmatrix)
+
+	blend := func(c1, c2 uint16, w uint32) uint16 {
+		return uint16((uint32(c1)*w + uint32(c2)*(255-w)) / 255)
+	}
+	parallelRows(0, h, func(y0, y1 int) {
+		row1 := make([]uint16, 4*w)
+		row2 := make([]uint16, 4*w)
+		for y := y0; y < y1; y++ {
+			readRow(img1, y, 0, w, row1)
+			readRow(img2, y, 0, w, row2)
+			for i, x := 0, 0; i < len(row1); i, x = i+4, x+1 {
+				w := uint32(mask.weight(x, y))
+				row1[i+0] = blend(row1[i+0], row2[i+0], w)
+				row1[i+1] = blend(row1[i+1], row2[i+1], w)
+				row1[i+2] = blend(row1[i+2], row2[i+2], w)
+				row1[i+3] = blend(row1[i+3], row2[i+3], w)
+			}
+			writeRow64(combined, y, row1)
+		}
+	})
 	return combined
 }
 
 // LevelImage sets the RGB values of the given image to be within the specified range.
+// If low is greater than high the colours are inverted.
 //     img  : Source image
 //     low  : Lowest RGB value in range
 //     high : Highest RGB value in range
 func LevelImage(img image.Image, low uint8, high uint8) *image.RGBA {
-	out := image.NewRGBA(img.Bounds())
-	b := img.Bounds()
-	level := func(n, low, high uint8) uint8 {
-		return uint8((float64(n)/255.0)*float64(high-low)) + low
-	}
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			out.Set(x, y, color.RGBA{
-				level(uint8(r>>8), low, high),
-				level(uint8(g>>8), low, high),
-				level(uint8(b>>8), low, high),
-				uint8(a >> 8),
-			})
-		}
-	}
-	return out
+	return LevelChannels(img, UniformLevels(Range{Low: low, High: high}))
 }
 
 // ScaleBrightness scales the brightness of the given image by the amount `value`
@@ -108,27 +211,92 @@ func LevelImage(img image.Image, low uint8, high uint8) *image.RGBA {
 func ScaleBrightness(img image.Image, scale float64) *image.RGBA {
 	out := image.NewRGBA(img.Bounds())
 	b := img.Bounds()
-	brighten := func(n uint32, scale float64) uint8 {
-		b := uint32(float64(n)*scale) >> 8
-		if b > 255 {
-			b = 255
-		}
-		if b < 0 {
-			b = 0
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		row := make([]uint16, 4*b.Dx())
+		for y := y0; y < y1; y++ {
+			readRow(img, y, b.Min.X, b.Max.X, row)
+			pix := out.Pix[out.PixOffset(b.Min.X, y):]
+			for i := 0; i < len(row); i += 4 {
+				pix[i+0] = brighten(row[i+0], scale)
+				pix[i+1] = brighten(row[i+1], scale)
+				pix[i+2] = brighten(row[i+2], scale)
+				pix[i+3] = uint8(row[i+3] >> 8)
+			}
 		}
-		return uint8(b)
+	})
+	return out
+}
+
+// brighten multiplies the 16 bit value n by scale and returns it as 8 bits.
+func brighten(n uint16, scale float64) uint8 {
+	b := uint32(float64(n)*scale) >> 8
+	if b > 255 {
+		b = 255
 	}
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		for x := b.Min.X; x < b.Max.X; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			out.Set(x, y, color.RGBA{
-				brighten(r, scale),
-				brighten(g, scale),
-				brighten(b, scale),
-				uint8(a >> 8),
-			})
+	return uint8(b)
+}
+
+// ScaleBrightness16 is ScaleBrightness for the 16 bit pipeline.
+//    img    : source image
+//    scale  : value to multiply the colour of all pixels in the image by.
+func ScaleBrightness16(img image.Image, scale float64) *image.RGBA64 {
+	b := img.Bounds()
+	out := image.NewRGBA64(b)
+	brighten16 := func(n uint16, scale float64) uint16 {
+		v := float64(n) * scale
+		if v > 0xffff {
+			v = 0xffff
 		}
+		return uint16(v)
 	}
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		row := make([]uint16, 4*b.Dx())
+		for y := y0; y < y1; y++ {
+			readRow(img, y, b.Min.X, b.Max.X, row)
+			for i := 0; i < len(row); i += 4 {
+				row[i+0] = brighten16(row[i+0], scale)
+				row[i+1] = brighten16(row[i+1], scale)
+				row[i+2] = brighten16(row[i+2], scale)
+			}
+			writeRow64(out, y, row)
+		}
+	})
+	return out
+}
+
+// Grayscale converts the colour of every pixel in the image to its
+// luminance. Alpha is kept.
+//    img : source image
+func Grayscale(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		row := make([]uint16, 4*b.Dx())
+		for y := y0; y < y1; y++ {
+			readRow(img, y, b.Min.X, b.Max.X, row)
+			grayRow(row)
+			pix := out.Pix[out.PixOffset(b.Min.X, y):]
+			for i, v := range row {
+				pix[i] = uint8(v >> 8)
+			}
+		}
+	})
+	return out
+}
+
+// Grayscale16 is Grayscale for the 16 bit pipeline.
+//    img : source image
+func Grayscale16(img image.Image) *image.RGBA64 {
+	b := img.Bounds()
+	out := image.NewRGBA64(b)
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		row := make([]uint16, 4*b.Dx())
+		for y := y0; y < y1; y++ {
+			readRow(img, y, b.Min.X, b.Max.X, row)
+			grayRow(row)
+			writeRow64(out, y, row)
+		}
+	})
 	return out
 }
 
@@ -142,3 +310,13 @@ func ScaleBrightness(img image.Image, scale float64) *image.RGBA {
 func Encode(w io.Writer, img image.Image, gAMA uint32) error {
 	return gamapng.Encode(w, img, gAMA)
 }
+
+// EncodeWith encodes the image like Encode using the compression
+// settings of enc.
+//    w    : destination writer.
+//    img  : image to encode
+//    gAMA : gAMA value to give the image.
+//    enc  : encoder settings
+func EncodeWith(w io.Writer, img image.Image, gAMA uint32, enc *gamapng.Encoder) error {
+	return enc.Encode(w, img, gAMA)
+}
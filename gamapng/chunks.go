@@ -0,0 +1,260 @@
+package gamapng
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// ChunkPosition is where an extra chunk is written relative to the
+// PLTE and IDAT chunks.
+type ChunkPosition int
+
+// Chunk positions
+const (
+	// BeforePLTE chunks follow IHDR and the colour chunks.
+	BeforePLTE ChunkPosition = iota
+
+	// AfterPLTE chunks follow PLTE and tRNS, just before the image data.
+	// In images without a palette they follow the BeforePLTE chunks.
+	AfterPLTE
+
+	// AfterIDAT chunks follow the image data, just before IEND.
+	AfterIDAT
+)
+
+// chunkBeforeIDAT lists the standard ancillary chunks that must come before
+// the image data, and whether they must also come before PLTE.
+var chunkBeforeIDAT = map[string]bool{
+	"cHRM": true,
+	"gAMA": true,
+	"iCCP": true,
+	"sBIT": true,
+	"sRGB": true,
+	"bKGD": false,
+	"hIST": false,
+	"tRNS": false,
+	"pHYs": false,
+	"sPLT": false,
+}
+
+// chunkLength is the data length of the standard chunks with fixed sizes.
+var chunkLength = map[string]int{
+	"cHRM": 32,
+	"gAMA": 4,
+	"pHYs": 9,
+	"sRGB": 1,
+	"tIME": 7,
+}
+
+// checkLength reports whether c has the length its type requires.
+func checkLength(c Chunk) error {
+	if n, ok := chunkLength[c.Type]; ok && len(c.Data) != n {
+		return FormatError("bad " + c.Type + " length")
+	}
+	return nil
+}
+
+// checkChunk reports whether c can be written at its position.
+// The type must be four ASCII letters with an uppercase third letter,
+// and only ancillary chunks, those with a lowercase first letter,
+// can be added to an image. Colour chunks must be given in
+// Encoder.ColorChunks instead.
+func checkChunk(c Chunk) error {
+	if len(c.Type) != 4 {
+		return FormatError("chunk type must be four letters: " + c.Type)
+	}
+	for i := 0; i < 4; i++ {
+		if b := c.Type[i]; !('a' <= b && b <= 'z' || 'A' <= b && b <= 'Z') {
+			return FormatError("chunk type must be four letters: " + c.Type)
+		}
+	}
+	if c.Type[0] < 'a' {
+		return UnsupportedError("critical chunk " + c.Type)
+	}
+	if c.Type[2] >= 'a' {
+		return FormatError("chunk type has the reserved bit set: " + c.Type)
+	}
+	switch c.Type {
+	case "gAMA", "cHRM", "sRGB", "iCCP":
+		return UnsupportedError("colour chunk " + c.Type + " outside of ColorChunks")
+	}
+	if err := checkLength(c); err != nil {
+		return err
+	}
+	if c.Position < BeforePLTE || c.Position > AfterIDAT {
+		return FormatError("invalid position of chunk " + c.Type)
+	}
+	if beforePLTE, ok := chunkBeforeIDAT[c.Type]; ok {
+		if c.Position == AfterIDAT || beforePLTE && c.Position != BeforePLTE {
+			return FormatError("chunk " + c.Type + " is out of order")
+		}
+	}
+	return nil
+}
+
+// ReadChunks reads every chunk of a PNG image in file order, up to and
+// including IEND, without decoding the image. The checksum of each chunk
+// is verified. The Position of ancillary chunks is set from where they are
+// relative to the PLTE and IDAT chunks.
+func ReadChunks(r io.Reader) ([]Chunk, error) {
+	var tmp [8]byte
+	if _, err := io.ReadFull(r, tmp[:]); err != nil {
+		return nil, err
+	}
+	if string(tmp[:]) != pngHeader {
+		return nil, FormatError("not a PNG file")
+	}
+
+	var (
+		chunks   []Chunk
+		position = BeforePLTE
+	)
+	for {
+		if _, err := io.ReadFull(r, tmp[:8]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return chunks, err
+		}
+		length := binary.BigEndian.Uint32(tmp[:4])
+		if length > 0x7fffffff {
+			return chunks, FormatError("bad chunk length: " + string(tmp[4:8]))
+		}
+		typ := string(tmp[4:8])
+
+		// Grow the buffer as data arrives, in case the length is bogus.
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, r, int64(length)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return chunks, err
+		}
+		crc := crc32.NewIEEE()
+		crc.Write(tmp[4:8])
+		crc.Write(buf.Bytes())
+		if _, err := io.ReadFull(r, tmp[:4]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return chunks, err
+		}
+		if binary.BigEndian.Uint32(tmp[:4]) != crc.Sum32() {
+			return chunks, FormatError("invalid checksum")
+		}
+
+		c := Chunk{Type: typ, Data: buf.Bytes()}
+		switch {
+		case typ == "PLTE":
+			position = AfterPLTE
+		case typ == "IDAT":
+			position = AfterIDAT
+		case typ[0] >= 'a':
+			c.Position = position
+		}
+		chunks = append(chunks, c)
+		if typ == "IEND" {
+			return chunks, nil
+		}
+	}
+}
+
+// TIMEChunk returns a tIME chunk holding the last modification time t.
+func TIMEChunk(t time.Time) Chunk {
+	t = t.UTC()
+	data := make([]byte, 7)
+	binary.BigEndian.PutUint16(data[0:2], uint16(t.Year()))
+	data[2] = uint8(t.Month())
+	data[3] = uint8(t.Day())
+	data[4] = uint8(t.Hour())
+	data[5] = uint8(t.Minute())
+	data[6] = uint8(t.Second())
+	return Chunk{Type: "tIME", Data: data, Position: AfterIDAT}
+}
+
+// PHYSChunk returns a pHYs chunk.
+func PHYSChunk(p PhysicalDimensions) Chunk {
+	data := make([]byte, 9)
+	binary.BigEndian.PutUint32(data[0:4], p.X)
+	binary.BigEndian.PutUint32(data[4:8], p.Y)
+	data[8] = p.Unit
+	return Chunk{Type: "pHYs", Data: data}
+}
+
+// EncodeTextChunk returns a tEXt, zTXt or iTXt chunk depending on t.Type,
+// or a tEXt chunk if it is empty. Text is compressed in zTXt chunks, and in
+// iTXt chunks when t.Compressed is set. tEXt and zTXt chunks can only hold
+// Latin-1 text.
+func EncodeTextChunk(t TextChunk) (Chunk, error) {
+	if err := checkKeyword(t.Keyword); err != nil {
+		return Chunk{}, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(t.Keyword)
+	buf.WriteByte(0)
+
+	switch t.Type {
+	case "", "tEXt", "zTXt":
+		text, ok := toLatin1(t.Text)
+		if !ok {
+			return Chunk{}, FormatError("text can not be written as Latin-1, use iTXt")
+		}
+		if t.Type != "zTXt" {
+			buf.Write(text)
+			return Chunk{Type: "tEXt", Data: buf.Bytes(), Position: AfterIDAT}, nil
+		}
+		buf.WriteByte(0) // zlib compression method
+		if err := deflateTo(&buf, text); err != nil {
+			return Chunk{}, err
+		}
+		return Chunk{Type: "zTXt", Data: buf.Bytes(), Position: AfterIDAT}, nil
+	case "iTXt":
+		if !utf8.ValidString(t.Text) || !utf8.ValidString(t.TranslatedKeyword) {
+			return Chunk{}, FormatError("iTXt text must be UTF-8")
+		}
+		if t.Compressed {
+			buf.Write([]byte{1, 0})
+		} else {
+			buf.Write([]byte{0, 0})
+		}
+		buf.WriteString(t.LanguageTag)
+		buf.WriteByte(0)
+		buf.WriteString(t.TranslatedKeyword)
+		buf.WriteByte(0)
+		if t.Compressed {
+			if err := deflateTo(&buf, []byte(t.Text)); err != nil {
+				return Chunk{}, err
+			}
+		} else {
+			buf.WriteString(t.Text)
+		}
+		return Chunk{Type: "iTXt", Data: buf.Bytes(), Position: AfterIDAT}, nil
+	}
+	return Chunk{}, UnsupportedError("text chunk " + t.Type)
+}
+
+// deflateTo writes b compressed with zlib to buf.
+func deflateTo(buf *bytes.Buffer, b []byte) error {
+	zw := zlib.NewWriter(buf)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// toLatin1 converts s to Latin-1, reporting false if it has other characters.
+func toLatin1(s string) ([]byte, bool) {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff || r == utf8.RuneError {
+			return nil, false
+		}
+		b = append(b, byte(r))
+	}
+	return b, true
+}
@@ -0,0 +1,45 @@
+package gamapng
+
+import (
+	"image"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestEncoderChecksChunks(t *testing.T) {
+	text, err := EncodeTextChunk(TextChunk{Keyword: "Comment", Text: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		enc   Encoder
+		valid bool
+	}{
+		{"tIME", Encoder{Chunks: []Chunk{TIMEChunk(time.Now())}}, true},
+		{"pHYs", Encoder{Chunks: []Chunk{PHYSChunk(PhysicalDimensions{X: 2835, Y: 2835, Unit: 1})}}, true},
+		{"text", Encoder{Chunks: []Chunk{text}}, true},
+		{"short tIME", Encoder{Chunks: []Chunk{{Type: "tIME", Data: []byte{1, 2, 3}, Position: AfterIDAT}}}, false},
+		{"long pHYs", Encoder{Chunks: []Chunk{{Type: "pHYs", Data: make([]byte, 10)}}}, false},
+		{"critical", Encoder{Chunks: []Chunk{{Type: "PLTE", Data: make([]byte, 3)}}}, false},
+		{"gAMA in Chunks", Encoder{Chunks: []Chunk{GAMAChunk(2300)}}, false},
+		{"sRGB in Chunks", Encoder{Chunks: []Chunk{SRGBChunk(Perceptual)}}, false},
+		{"cHRM in Chunks", Encoder{Chunks: []Chunk{CHRMChunk(SRGBChromaticities)}}, false},
+		{"iCCP in Chunks", Encoder{Chunks: []Chunk{{Type: "iCCP", Data: []byte("p\x00\x00")}}}, false},
+		{"short gAMA", Encoder{ColorChunks: []Chunk{{Type: "gAMA", Data: []byte{1}}}}, false},
+		{"long sRGB", Encoder{ColorChunks: []Chunk{{Type: "sRGB", Data: []byte{0, 0}}}}, false},
+		{"short cHRM", Encoder{ColorChunks: []Chunk{{Type: "cHRM", Data: make([]byte, 31)}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.enc.Encode(ioutil.Discard, image.NewRGBA(image.Rect(0, 0, 1, 1)), 2300)
+			if tt.valid && err != nil {
+				t.Errorf("Encode: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Encode accepted an invalid chunk")
+			}
+		})
+	}
+}
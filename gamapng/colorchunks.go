@@ -0,0 +1,112 @@
+package gamapng
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// SRGBChromaticities are the chromaticities of the sRGB colour space,
+// as recommended for the cHRM chunk of images with an sRGB chunk.
+var SRGBChromaticities = Chromaticities{
+	WhiteX: 31270, WhiteY: 32900,
+	RedX: 64000, RedY: 33000,
+	GreenX: 30000, GreenY: 60000,
+	BlueX: 15000, BlueY: 6000,
+}
+
+// GAMAChunk returns a gAMA chunk. gAMA is multiplied by 100,000.
+func GAMAChunk(gAMA uint32) Chunk {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, gAMA)
+	return Chunk{Type: "gAMA", Data: data}
+}
+
+// SRGBChunk returns an sRGB chunk with the given rendering intent.
+func SRGBChunk(intent RenderingIntent) Chunk {
+	return Chunk{Type: "sRGB", Data: []byte{byte(intent)}}
+}
+
+// CHRMChunk returns a cHRM chunk.
+func CHRMChunk(c Chromaticities) Chunk {
+	data := make([]byte, 32)
+	for i, v := range [8]uint32{
+		c.WhiteX, c.WhiteY, c.RedX, c.RedY,
+		c.GreenX, c.GreenY, c.BlueX, c.BlueY,
+	} {
+		binary.BigEndian.PutUint32(data[4*i:], v)
+	}
+	return Chunk{Type: "cHRM", Data: data}
+}
+
+// ICCPChunk returns an iCCP chunk with the profile compressed.
+// The name must be 1 to 79 printable ASCII characters.
+func ICCPChunk(p ICCProfile) (Chunk, error) {
+	if err := checkKeyword(p.Name); err != nil {
+		return Chunk{}, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(p.Name)
+	buf.WriteByte(0)
+	buf.WriteByte(0) // zlib compression method
+	if err := deflateTo(&buf, p.Profile); err != nil {
+		return Chunk{}, err
+	}
+	return Chunk{Type: "iCCP", Data: buf.Bytes()}, nil
+}
+
+// checkKeyword reports whether k is a valid keyword or profile name.
+func checkKeyword(k string) error {
+	if len(k) < 1 || len(k) > 79 {
+		return FormatError("keyword must be 1 to 79 characters")
+	}
+	for i := 0; i < len(k); i++ {
+		if c := k[i]; c < 0x20 || c > 0x7e {
+			return FormatError("keyword has a non printable character")
+		}
+	}
+	if k[0] == ' ' || k[len(k)-1] == ' ' {
+		return FormatError("keyword has leading or trailing spaces")
+	}
+	return nil
+}
+
+// Presets of colour chunks for Encoder.ColorChunks. Which image a viewer
+// shows depends on which of these chunks its decoder understands and which
+// one it gives priority to.
+
+// GAMAOnly writes just the gAMA chunk, the same as leaving ColorChunks nil.
+// Every decoder that supports gAMA applies it.
+func GAMAOnly(gAMA uint32) []Chunk {
+	return []Chunk{GAMAChunk(gAMA)}
+}
+
+// SRGBFirst writes an sRGB chunk followed by gAMA. Decoders that give sRGB
+// priority, as the PNG specification recommends, ignore gAMA, while decoders
+// that only support gAMA or let the last chunk win still apply it.
+func SRGBFirst(gAMA uint32) []Chunk {
+	return []Chunk{SRGBChunk(Perceptual), GAMAChunk(gAMA)}
+}
+
+// SRGBLast writes gAMA followed by an sRGB chunk. Decoders that support
+// sRGB ignore gAMA whichever chunk they give priority to, so only decoders
+// that support gAMA but not sRGB apply it.
+func SRGBLast(gAMA uint32) []Chunk {
+	return []Chunk{GAMAChunk(gAMA), SRGBChunk(Perceptual)}
+}
+
+// WithCHRM writes gAMA and the sRGB chromaticities in cHRM. Colour managed
+// decoders that build a profile from gAMA only when cHRM is present apply
+// gAMA as well.
+func WithCHRM(gAMA uint32) []Chunk {
+	return []Chunk{GAMAChunk(gAMA), CHRMChunk(SRGBChromaticities)}
+}
+
+// ICCFirst writes an iCCP chunk with the given profile followed by gAMA.
+// Decoders with ICC support use the profile and ignore gAMA.
+func ICCFirst(gAMA uint32, p ICCProfile) ([]Chunk, error) {
+	iccp, err := ICCPChunk(p)
+	if err != nil {
+		return nil, err
+	}
+	return []Chunk{iccp, GAMAChunk(gAMA)}, nil
+}
@@ -0,0 +1,122 @@
+package gamapng
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"io/ioutil"
+	"testing"
+)
+
+// colorChunks encodes a small image with enc and returns the chunks
+// between IHDR and the first IDAT.
+func colorChunks(t *testing.T, enc *Encoder, gAMA uint32) []Chunk {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 3, 2)), gAMA); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := ReadChunks(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) == 0 || chunks[0].Type != "IHDR" {
+		t.Fatalf("first chunk is not IHDR: %v", chunks)
+	}
+	var between []Chunk
+	for _, c := range chunks[1:] {
+		if c.Type == "IDAT" {
+			return between
+		}
+		between = append(between, c)
+	}
+	t.Fatal("no IDAT chunk")
+	return nil
+}
+
+// Chunk data spelled out byte by byte.
+var (
+	gAMA2300 = []byte{0x00, 0x00, 0x08, 0xfc}
+	sRGB0    = []byte{0x00}
+	cHRMsRGB = []byte{
+		0x00, 0x00, 0x7a, 0x26, 0x00, 0x00, 0x80, 0x84, // white 31270, 32900
+		0x00, 0x00, 0xfa, 0x00, 0x00, 0x00, 0x80, 0xe8, // red 64000, 33000
+		0x00, 0x00, 0x75, 0x30, 0x00, 0x00, 0xea, 0x60, // green 30000, 60000
+		0x00, 0x00, 0x3a, 0x98, 0x00, 0x00, 0x17, 0x70, // blue 15000, 6000
+	}
+)
+
+func TestColorChunkPresets(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []Chunk
+		want   []Chunk
+	}{
+		{"nil", nil, []Chunk{{Type: "gAMA", Data: gAMA2300}}},
+		{"empty", []Chunk{}, nil},
+		{"GAMAOnly", GAMAOnly(2300), []Chunk{{Type: "gAMA", Data: gAMA2300}}},
+		{"SRGBFirst", SRGBFirst(2300), []Chunk{{Type: "sRGB", Data: sRGB0}, {Type: "gAMA", Data: gAMA2300}}},
+		{"SRGBLast", SRGBLast(2300), []Chunk{{Type: "gAMA", Data: gAMA2300}, {Type: "sRGB", Data: sRGB0}}},
+		{"WithCHRM", WithCHRM(2300), []Chunk{{Type: "gAMA", Data: gAMA2300}, {Type: "cHRM", Data: cHRMsRGB}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := colorChunks(t, &Encoder{ColorChunks: tt.chunks}, 2300)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d chunks %v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if got[i].Type != tt.want[i].Type || !bytes.Equal(got[i].Data, tt.want[i].Data) {
+					t.Errorf("chunk %d is %s % x, want %s % x", i,
+						got[i].Type, got[i].Data, tt.want[i].Type, tt.want[i].Data)
+				}
+			}
+		})
+	}
+}
+
+func TestICCFirst(t *testing.T) {
+	profile := bytes.Repeat([]byte("profile data "), 20)
+	chunks, err := ICCFirst(2300, ICCProfile{Name: "Test profile", Profile: profile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := colorChunks(t, &Encoder{ColorChunks: chunks}, 2300)
+	if len(got) != 2 || got[0].Type != "iCCP" || got[1].Type != "gAMA" {
+		t.Fatalf("got chunks %v, want iCCP and gAMA", got)
+	}
+	if !bytes.Equal(got[1].Data, gAMA2300) {
+		t.Errorf("gAMA data is % x, want % x", got[1].Data, gAMA2300)
+	}
+
+	// The name, its null separator and the compression method are followed
+	// by the zlib compressed profile, whose bytes depend on the compressor.
+	prefix := []byte("Test profile\x00\x00")
+	data := got[0].Data
+	if !bytes.HasPrefix(data, prefix) {
+		t.Fatalf("iCCP data starts with %q, want %q", data[:len(prefix)], prefix)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data[len(prefix):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflated, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(inflated, profile) {
+		t.Errorf("iCCP profile is %q, want %q", inflated, profile)
+	}
+
+	if _, err := ICCFirst(2300, ICCProfile{Name: "", Profile: profile}); err == nil {
+		t.Error("no error for an empty profile name")
+	}
+}
+
+func TestColorChunksRejected(t *testing.T) {
+	enc := &Encoder{ColorChunks: []Chunk{{Type: "tEXt", Data: []byte("a\x00b")}}}
+	err := enc.Encode(ioutil.Discard, image.NewRGBA(image.Rect(0, 0, 1, 1)), 2300)
+	if err == nil {
+		t.Error("no error for a tEXt colour chunk")
+	}
+}
@@ -0,0 +1,310 @@
+package gamapng
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Metadata holds the ancillary chunks read from a PNG file.
+// Fields for chunks that were not present in the file are left
+// at their zero value.
+type Metadata struct {
+	// Gamma is the gAMA value multiplied by 100,000.
+	// Zero means that the file had no gAMA chunk.
+	Gamma uint32
+
+	Chromaticities *Chromaticities     // cHRM
+	SRGB           *RenderingIntent    // sRGB
+	ICCProfile     *ICCProfile         // iCCP
+	Physical       *PhysicalDimensions // pHYs
+	Text           []TextChunk         // tEXt, zTXt and iTXt in file order
+	Time           *time.Time          // tIME
+
+	// Unknown holds the raw data of every chunk the decoder
+	// does not understand or could not parse, in file order.
+	Unknown []Chunk
+}
+
+// Chunk is a raw PNG chunk.
+type Chunk struct {
+	Type string
+	Data []byte
+
+	// Position is where the chunk is written by Encoder.Chunks,
+	// or where it was found when decoded.
+	Position ChunkPosition
+}
+
+// Chromaticities holds the values of a cHRM chunk.
+// Each value is multiplied by 100,000.
+type Chromaticities struct {
+	WhiteX, WhiteY uint32
+	RedX, RedY     uint32
+	GreenX, GreenY uint32
+	BlueX, BlueY   uint32
+}
+
+// RenderingIntent is the rendering intent stored in an sRGB chunk.
+type RenderingIntent uint8
+
+// Rendering intents, as per the PNG spec.
+const (
+	Perceptual           RenderingIntent = 0
+	RelativeColorimetric RenderingIntent = 1
+	Saturation           RenderingIntent = 2
+	AbsoluteColorimetric RenderingIntent = 3
+)
+
+// ICCProfile holds the values of an iCCP chunk.
+// Profile is the decompressed profile data.
+type ICCProfile struct {
+	Name    string
+	Profile []byte
+}
+
+// PhysicalDimensions holds the values of a pHYs chunk.
+type PhysicalDimensions struct {
+	X, Y uint32
+	// Unit is 1 when X and Y are pixels per metre,
+	// and 0 when they only give the aspect ratio.
+	Unit uint8
+}
+
+// TextChunk holds the values of a tEXt, zTXt or iTXt chunk.
+// Text is always decompressed and converted to UTF-8.
+type TextChunk struct {
+	Type              string // "tEXt", "zTXt" or "iTXt"
+	Keyword           string
+	Text              string
+	Compressed        bool
+	LanguageTag       string // iTXt only
+	TranslatedKeyword string // iTXt only
+}
+
+// maxInflatedSize is the largest decompressed size of an iCCP, zTXt or
+// iTXt chunk, so that a small chunk can not exhaust memory.
+const maxInflatedSize = 32 << 20
+
+// readChunkData reads the data of an ancillary chunk of the given length.
+func (d *decoder) readChunkData(length uint32) ([]byte, error) {
+	if length > 0x7fffffff {
+		return nil, FormatError(fmt.Sprintf("Bad chunk length: %d", length))
+	}
+	// Grow the buffer as data arrives, in case the length is bogus.
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	d.crc.Write(buf.Bytes())
+	return buf.Bytes(), d.verifyChecksum()
+}
+
+// parseAncillary reads an ancillary chunk into d.meta. As the PNG spec
+// recommends, a malformed chunk does not fail the decode but is kept in
+// d.meta.Unknown.
+func (d *decoder) parseAncillary(name string, length uint32) error {
+	data, err := d.readChunkData(length)
+	if err != nil {
+		return err
+	}
+	if err := d.parseMetadata(name, data); err != nil {
+		d.meta.Unknown = append(d.meta.Unknown, Chunk{Type: name, Data: data, Position: d.position()})
+	}
+	return nil
+}
+
+// parseMetadata parses the data of an ancillary chunk into d.meta.
+func (d *decoder) parseMetadata(name string, data []byte) error {
+	m := d.meta
+
+	switch name {
+	case "gAMA":
+		if len(data) != 4 {
+			return FormatError("bad gAMA length")
+		}
+		m.Gamma = binary.BigEndian.Uint32(data)
+	case "cHRM":
+		if len(data) != 32 {
+			return FormatError("bad cHRM length")
+		}
+		var v [8]uint32
+		for i := range v {
+			v[i] = binary.BigEndian.Uint32(data[4*i:])
+		}
+		m.Chromaticities = &Chromaticities{v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]}
+	case "sRGB":
+		if len(data) != 1 {
+			return FormatError("bad sRGB length")
+		}
+		intent := RenderingIntent(data[0])
+		m.SRGB = &intent
+	case "iCCP":
+		name, rest, err := splitKeyword(data)
+		if err != nil || len(rest) < 1 {
+			return FormatError("bad iCCP chunk")
+		}
+		profile, err := inflate(rest[0], rest[1:])
+		if err != nil {
+			return err
+		}
+		m.ICCProfile = &ICCProfile{Name: name, Profile: profile}
+	case "pHYs":
+		if len(data) != 9 {
+			return FormatError("bad pHYs length")
+		}
+		m.Physical = &PhysicalDimensions{
+			X:    binary.BigEndian.Uint32(data[0:4]),
+			Y:    binary.BigEndian.Uint32(data[4:8]),
+			Unit: data[8],
+		}
+	case "tEXt":
+		keyword, rest, err := splitKeyword(data)
+		if err != nil {
+			return FormatError("bad tEXt chunk")
+		}
+		m.Text = append(m.Text, TextChunk{
+			Type:    name,
+			Keyword: keyword,
+			Text:    latin1(rest),
+		})
+	case "zTXt":
+		keyword, rest, err := splitKeyword(data)
+		if err != nil || len(rest) < 1 {
+			return FormatError("bad zTXt chunk")
+		}
+		text, err := inflate(rest[0], rest[1:])
+		if err != nil {
+			return err
+		}
+		m.Text = append(m.Text, TextChunk{
+			Type:       name,
+			Keyword:    keyword,
+			Text:       latin1(text),
+			Compressed: true,
+		})
+	case "iTXt":
+		t, err := parseITXt(data)
+		if err != nil {
+			return err
+		}
+		m.Text = append(m.Text, t)
+	case "tIME":
+		if len(data) != 7 {
+			return FormatError("bad tIME length")
+		}
+		t := time.Date(
+			int(binary.BigEndian.Uint16(data[0:2])),
+			time.Month(data[2]),
+			int(data[3]),
+			int(data[4]),
+			int(data[5]),
+			int(data[6]),
+			0, time.UTC,
+		)
+		m.Time = &t
+	default:
+		m.Unknown = append(m.Unknown, Chunk{Type: name, Data: data, Position: d.position()})
+	}
+	return nil
+}
+
+// position returns the position of the chunk being read.
+func (d *decoder) position() ChunkPosition {
+	switch d.stage {
+	case dsSeenPLTE, dsSeentRNS:
+		return AfterPLTE
+	case dsSeenIDAT:
+		return AfterIDAT
+	}
+	return BeforePLTE
+}
+
+func parseITXt(data []byte) (TextChunk, error) {
+	t := TextChunk{Type: "iTXt"}
+	keyword, rest, err := splitKeyword(data)
+	if err != nil || len(rest) < 2 {
+		return t, FormatError("bad iTXt chunk")
+	}
+	t.Keyword = keyword
+	t.Compressed = rest[0] != 0
+	method := rest[1]
+	t.LanguageTag, rest, err = splitKeyword(rest[2:])
+	if err != nil {
+		return t, FormatError("bad iTXt chunk")
+	}
+	t.TranslatedKeyword, rest, err = splitKeyword(rest)
+	if err != nil {
+		return t, FormatError("bad iTXt chunk")
+	}
+	if t.Compressed {
+		rest, err = inflate(method, rest)
+		if err != nil {
+			return t, err
+		}
+	}
+	t.Text = string(rest)
+	return t, nil
+}
+
+// splitKeyword splits a null terminated keyword from the start of b.
+func splitKeyword(b []byte) (string, []byte, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", nil, FormatError("missing null separator")
+	}
+	return string(b[:i]), b[i+1:], nil
+}
+
+// inflate decompresses b with the given PNG compression method.
+func inflate(method byte, b []byte) ([]byte, error) {
+	if method != 0 {
+		return nil, UnsupportedError("compression method")
+	}
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, FormatError(err.Error())
+	}
+	defer r.Close()
+	out, err := ioutil.ReadAll(io.LimitReader(r, maxInflatedSize+1))
+	if err != nil {
+		return nil, FormatError(err.Error())
+	}
+	if len(out) > maxInflatedSize {
+		return nil, FormatError("decompressed chunk too large")
+	}
+	return out, nil
+}
+
+// latin1 converts ISO 8859-1 text, which tEXt and zTXt chunks use, to UTF-8.
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// DecodeWithMetadata reads a PNG image from r and returns it along with
+// the ancillary chunks found in the file.
+func DecodeWithMetadata(r io.Reader) (image.Image, *Metadata, error) {
+	d := &decoder{
+		r:    r,
+		crc:  crc32.NewIEEE(),
+		meta: &Metadata{},
+	}
+	img, err := d.decodeAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, d.meta, nil
+}
@@ -0,0 +1,101 @@
+package gamapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"runtime"
+	"testing"
+)
+
+// rawChunk returns the bytes of a chunk with the given type and data,
+// written with the given length.
+func rawChunk(typ string, length uint32, data []byte) []byte {
+	b := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(b, length)
+	copy(b[4:], typ)
+	b = append(b, data...)
+	return append(b, crc(b[4:])...)
+}
+
+// crc returns the big endian checksum of b.
+func crc(b []byte) []byte {
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(b))
+	return sum
+}
+
+// withChunk encodes a small image and inserts chunk after IHDR.
+func withChunk(t *testing.T, chunk []byte) []byte {
+	var buf bytes.Buffer
+	if err := Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), 45455); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	const ihdrEnd = 8 + 12 + 13
+	out := append([]byte{}, b[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, b[ihdrEnd:]...)
+}
+
+func TestDecodeWithMetadataSkipsMalformedChunks(t *testing.T) {
+	var zero bytes.Buffer
+	deflateTo(&zero, make([]byte, 1024))
+	tests := []struct {
+		name string
+		typ  string
+		data []byte
+	}{
+		{"short tIME", "tIME", []byte{1, 2, 3}},
+		{"long gAMA", "gAMA", []byte{0, 0, 0, 1, 2}},
+		{"tEXt without separator", "tEXt", []byte("comment")},
+		{"corrupt zTXt", "zTXt", []byte("k\x00\x00garbage")},
+		{"iCCP with unknown method", "iCCP", append([]byte("p\x00\x07"), zero.Bytes()...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := withChunk(t, rawChunk(tt.typ, uint32(len(tt.data)), tt.data))
+			if _, err := Decode(bytes.NewReader(data)); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			_, meta, err := DecodeWithMetadata(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("DecodeWithMetadata: %v", err)
+			}
+			if len(meta.Unknown) != 1 || meta.Unknown[0].Type != tt.typ || !bytes.Equal(meta.Unknown[0].Data, tt.data) {
+				t.Errorf("Unknown = %+v, want the raw %s chunk", meta.Unknown, tt.typ)
+			}
+		})
+	}
+}
+
+func TestDecodeWithMetadataBogusLength(t *testing.T) {
+	// A chunk claiming 2 GiB of data in a tiny file must fail without
+	// allocating the claimed length.
+	data := withChunk(t, rawChunk("tEXt", 0x7fffffff, []byte("a\x00b")))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, _, err := DecodeWithMetadata(bytes.NewReader(data)); err == nil {
+		t.Error("no error for a truncated chunk")
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes for a %d byte file", n, len(data))
+	}
+}
+
+func TestInflateLimit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := deflateTo(&buf, make([]byte, maxInflatedSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inflate(0, buf.Bytes()); err == nil {
+		t.Error("no error for a chunk above maxInflatedSize")
+	}
+	buf.Reset()
+	deflateTo(&buf, make([]byte, 1000))
+	if out, err := inflate(0, buf.Bytes()); err != nil || len(out) != 1000 {
+		t.Errorf("inflate = %d bytes, %v", len(out), err)
+	}
+}
@@ -114,6 +114,10 @@ type decoder struct {
 	tmp           [3 * 256]byte
 	interlace     int
 
+	// meta collects the ancillary chunks when it is non-nil.
+	// Otherwise they are skipped.
+	meta *Metadata
+
 	// useTransparent and transparent are used for grayscale and truecolor
 	// transparency, as opposed to palette transparency.
 	useTransparent bool
@@ -897,6 +901,9 @@ func (d *decoder) parseChunk() error {
 		d.stage = dsSeenIEND
 		return d.parseIEND(length)
 	}
+	if d.meta != nil {
+		return d.parseAncillary(string(d.tmp[4:8]), length)
+	}
 	if length > 0x7fffffff {
 		return FormatError(fmt.Sprintf("Bad chunk length: %d", length))
 	}
@@ -941,6 +948,11 @@ func Decode(r io.Reader) (image.Image, error) {
 		r:   r,
 		crc: crc32.NewIEEE(),
 	}
+	return d.decodeAll()
+}
+
+// decodeAll reads every chunk up to and including IEND.
+func (d *decoder) decodeAll() (image.Image, error) {
 	if err := d.checkHeader(); err != nil {
 		if err == io.EOF {
 			err = io.ErrUnexpectedEOF
@@ -6,17 +6,64 @@ package gamapng
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
 	"compress/zlib"
+	"hash/adler32"
 	"hash/crc32"
 	"image"
 	"image/color"
+	"image/draw"
 	"io"
+	"math"
 	"strconv"
 )
 
 // Encoder configures encoding PNG images.
 type Encoder struct {
 	CompressionLevel CompressionLevel
+
+	// Filter selects the filter applied to each row before compression.
+	// The zero value is FilterAdaptive.
+	Filter FilterStrategy
+
+	// Workers is the number of goroutines compressing the image data.
+	// Values above 1 split the image into segments that are compressed
+	// concurrently, which is faster for large images but produces
+	// slightly larger files. Otherwise the image is compressed serially.
+	// Values above MaxWorkers are treated as MaxWorkers.
+	Workers int
+
+	// Interlace writes the image with Adam7 interlacing, so that viewers
+	// can show a low resolution version while it loads.
+	Interlace bool
+
+	// Grayscale writes images that are not paletted as grayscale, with an
+	// alpha channel if they are not opaque. Colours are converted to their
+	// luminance. Images with an 8 bit colour model are written with 8 bits
+	// per sample and other images with 16.
+	Grayscale bool
+
+	// ColorChunks are the colour space chunks written before the palette
+	// and image data, in order. If nil, a single gAMA chunk with the value
+	// passed to Encode is written, and a non-nil empty slice writes none.
+	// Only gAMA, cHRM, sRGB and iCCP chunks are allowed. See SRGBFirst
+	// and the other presets.
+	ColorChunks []Chunk
+
+	// Chunks are extra chunks written at their Position, after the
+	// colour chunks. They must be ancillary chunks other than the colour
+	// chunks. See EncodeTextChunk, TIMEChunk and PHYSChunk.
+	Chunks []Chunk
+
+	// Quantizer is used to produce a palette of at most 256 colours for
+	// images that are not already paletted. If nil, the image is written
+	// in truecolour.
+	Quantizer draw.Quantizer
+
+	// Drawer is used to convert the image to the palette produced by
+	// Quantizer. If nil, draw.FloydSteinberg is used.
+	Drawer draw.Drawer
 }
 
 type encoder struct {
@@ -40,10 +87,61 @@ const (
 	BestSpeed          CompressionLevel = -2
 	BestCompression    CompressionLevel = -3
 
-	// Positive CompressionLevel values are reserved to mean a numeric zlib
-	// compression level, although that is not implemented yet.
+	// Positive CompressionLevel values from 1 to 9 are numeric zlib
+	// compression levels, from fastest to smallest.
+)
+
+// FilterStrategy selects how the filter of each row is chosen.
+type FilterStrategy int
+
+// Filter strategies
+const (
+	// FilterAdaptive chooses the filter of each row with the smallest sum
+	// of absolute differences. This is the heuristic libpng uses.
+	FilterAdaptive FilterStrategy = iota
+
+	// FilterNone, FilterSub, FilterUp, FilterAverage and FilterPaeth
+	// apply the same filter to every row.
+	FilterNone
+	FilterSub
+	FilterUp
+	FilterAverage
+	FilterPaeth
+
+	// FilterEntropy chooses the filter of each row whose bytes have the
+	// lowest Shannon entropy. It is slower than FilterAdaptive but does
+	// better on images with repeating high frequency patterns.
+	FilterEntropy
 )
 
+var filterStrategyNames = [...]string{
+	FilterAdaptive: "adaptive",
+	FilterNone:     "none",
+	FilterSub:      "sub",
+	FilterUp:       "up",
+	FilterAverage:  "average",
+	FilterPaeth:    "paeth",
+	FilterEntropy:  "entropy",
+}
+
+func (f FilterStrategy) String() string {
+	if f < 0 || int(f) >= len(filterStrategyNames) {
+		return "FilterStrategy(" + strconv.Itoa(int(f)) + ")"
+	}
+	return filterStrategyNames[f]
+}
+
+// ParseFilterStrategy returns the filter strategy with the given name,
+// one of adaptive, none, sub, up, average, paeth or entropy.
+func ParseFilterStrategy(name string) (FilterStrategy, error) {
+	for f, n := range filterStrategyNames {
+		if n == name {
+			return FilterStrategy(f), nil
+		}
+	}
+	return 0, UnsupportedError("filter strategy " + name)
+}
+
 // Big-endian.
 func writeUint32(b []uint8, u uint32) {
 	b[0] = uint8(u >> 24)
@@ -56,6 +154,21 @@ type opaquer interface {
 	Opaque() bool
 }
 
+// RowImage is an image that can produce a whole row of pixels at once.
+// Encode reads images that implement it a row at a time instead of
+// calling At for every pixel, so an image that computes its rows on
+// demand never has to be held in memory. Its colour model should be
+// color.RGBAModel. ReadRow may be called concurrently when the encoder
+// has more than one worker. Implementing Opaque avoids reading the image
+// twice when it has no alpha.
+type RowImage interface {
+	image.Image
+
+	// ReadRow stores the alpha premultiplied 8 bit RGBA values of row y,
+	// laid out like image.RGBA.Pix, in dst, which holds 4*Bounds().Dx() bytes.
+	ReadRow(y int, dst []uint8)
+}
+
 // Returns whether or not the image is fully opaque.
 func opaque(m image.Image) bool {
 	if o, ok := m.(opaquer); ok {
@@ -129,9 +242,15 @@ func (e *encoder) writeIHDR() {
 	case cbTCA8:
 		e.tmp[8] = 8
 		e.tmp[9] = ctTrueColorAlpha
+	case cbGA8:
+		e.tmp[8] = 8
+		e.tmp[9] = ctGrayscaleAlpha
 	case cbG16:
 		e.tmp[8] = 16
 		e.tmp[9] = ctGrayscale
+	case cbGA16:
+		e.tmp[8] = 16
+		e.tmp[9] = ctGrayscaleAlpha
 	case cbTC16:
 		e.tmp[8] = 16
 		e.tmp[9] = ctTrueColor
@@ -141,7 +260,11 @@ func (e *encoder) writeIHDR() {
 	}
 	e.tmp[10] = 0 // default compression method
 	e.tmp[11] = 0 // default filter method
-	e.tmp[12] = 0 // non-interlaced
+	if e.enc.Interlace {
+		e.tmp[12] = itAdam7
+	} else {
+		e.tmp[12] = itNone
+	}
 	e.writeChunk(e.tmp[:13], "IHDR")
 }
 
@@ -150,6 +273,15 @@ func (e *encoder) writeGAMA(value uint32) {
 	e.writeChunk(e.tmp[:4], "gAMA")
 }
 
+// Writes the extra chunks at position p in order.
+func (e *encoder) writeChunks(p ChunkPosition) {
+	for _, c := range e.enc.Chunks {
+		if c.Position == p {
+			e.writeChunk(c.Data, c.Type)
+		}
+	}
+}
+
 func (e *encoder) writePLTEAndTRNS(p color.Palette) {
 	if len(p) < 1 || len(p) > 256 {
 		e.err = FormatError("bad palette length: " + strconv.Itoa(len(p)))
@@ -280,172 +412,554 @@ func filter(cr *[nFilter][]byte, pr []byte, bpp int) int {
 	return filter
 }
 
-func writeImage(w io.Writer, m image.Image, cb int, level int) error {
-	zw, err := zlib.NewWriterLevel(w, level)
-	if err != nil {
-		return err
+// Applies the filter f to the current row, storing the result in cr[f].
+func applyFilter(cr *[nFilter][]byte, pr []byte, bpp int, f int) {
+	cdat0 := cr[0][1:]
+	cdat := cr[f][1:]
+	pdat := pr[1:]
+	n := len(cdat0)
+
+	switch f {
+	case ftSub:
+		copy(cdat[:bpp], cdat0[:bpp])
+		for i := bpp; i < n; i++ {
+			cdat[i] = cdat0[i] - cdat0[i-bpp]
+		}
+	case ftUp:
+		for i := 0; i < n; i++ {
+			cdat[i] = cdat0[i] - pdat[i]
+		}
+	case ftAverage:
+		for i := 0; i < bpp; i++ {
+			cdat[i] = cdat0[i] - pdat[i]/2
+		}
+		for i := bpp; i < n; i++ {
+			cdat[i] = cdat0[i] - uint8((int(cdat0[i-bpp])+int(pdat[i]))/2)
+		}
+	case ftPaeth:
+		for i := 0; i < bpp; i++ {
+			cdat[i] = cdat0[i] - pdat[i]
+		}
+		for i := bpp; i < n; i++ {
+			cdat[i] = cdat0[i] - paeth(cdat0[i-bpp], pdat[i], pdat[i-bpp])
+		}
 	}
-	defer zw.Close()
+}
 
-	bpp := 0 // Bytes per pixel.
+// Chooses the filter whose output has the lowest Shannon entropy, and applies it.
+// The return value is the index of the filter and also of the row in cr that has had it applied.
+func filterEntropy(cr *[nFilter][]byte, pr []byte, bpp int) int {
+	best, filter := math.Inf(1), ftNone
+	var hist [256]int
+	for f := 0; f < nFilter; f++ {
+		applyFilter(cr, pr, bpp, f)
+		for i := range hist {
+			hist[i] = 0
+		}
+		cdat := cr[f][1:]
+		for _, v := range cdat {
+			hist[v]++
+		}
+		// n*log2(n) - sum(c*log2(c)) is the entropy of the row in bits.
+		n := float64(len(cdat))
+		bits := n * math.Log2(n)
+		for _, c := range hist {
+			if c > 0 {
+				bits -= float64(c) * math.Log2(float64(c))
+			}
+		}
+		if bits < best {
+			best, filter = bits, f
+		}
+	}
+	return filter
+}
 
+// Returns the number of bytes per pixel of the color type cb.
+func bytesPerPixel(cb int) int {
 	switch cb {
 	case cbG8:
-		bpp = 1
+		return 1
 	case cbTC8:
-		bpp = 3
+		return 3
 	case cbP8:
-		bpp = 1
+		return 1
 	case cbTCA8:
-		bpp = 4
+		return 4
+	case cbGA8:
+		return 2
 	case cbTC16:
-		bpp = 6
+		return 6
 	case cbTCA16:
-		bpp = 8
+		return 8
 	case cbG16:
-		bpp = 2
-	}
-	// cr[*] and pr are the bytes for the current and previous row.
-	// cr[0] is unfiltered (or equivalently, filtered with the ftNone filter).
-	// cr[ft], for non-zero filter types ft, are buffers for transforming cr[0] under the
-	// other PNG filter types. These buffers are allocated once and re-used for each row.
-	// The +1 is for the per-row filter type, which is at cr[*][0].
-	b := m.Bounds()
-	var cr [nFilter][]uint8
-	for i := range cr {
-		cr[i] = make([]uint8, 1+bpp*b.Dx())
-		cr[i][0] = uint8(i)
+		return 2
+	case cbGA16:
+		return 4
 	}
-	pr := make([]uint8, 1+bpp*b.Dx())
+	return 0
+}
 
-	gray, _ := m.(*image.Gray)
-	rgba, _ := m.(*image.RGBA)
-	paletted, _ := m.(*image.Paletted)
-	nrgba, _ := m.(*image.NRGBA)
+// A scanner converts the rows of an image, or of an Adam7 pass over it,
+// to the bytes of a PNG scanline.
+type scanner struct {
+	m        image.Image
+	cb       int
+	b        image.Rectangle
+	gray     *image.Gray
+	rgba     *image.RGBA
+	paletted *image.Paletted
+	nrgba    *image.NRGBA
+	rows     RowImage
 
-	for y := b.Min.Y; y < b.Max.Y; y++ {
-		// Convert from colors to bytes.
-		i := 1
-		switch cb {
-		case cbG8:
-			if gray != nil {
-				offset := (y - b.Min.Y) * gray.Stride
-				copy(cr[0][1:], gray.Pix[offset:offset+b.Dx()])
+	// Each row holds n pixels starting at x0 and dx apart.
+	x0, dx, n int
+}
+
+func newScanner(m image.Image, cb int) *scanner {
+	s := &scanner{m: m, cb: cb, b: m.Bounds()}
+	s.gray, _ = m.(*image.Gray)
+	s.rgba, _ = m.(*image.RGBA)
+	s.paletted, _ = m.(*image.Paletted)
+	s.nrgba, _ = m.(*image.NRGBA)
+	s.rows, _ = m.(RowImage)
+	s.x0, s.dx, s.n = s.b.Min.X, 1, s.b.Dx()
+	return s
+}
+
+// Converts row y from colors to bytes, storing them in cr0[1:].
+func (s *scanner) row(y int, cr0 []uint8) {
+	m, b := s.m, s.b
+	gray, rgba, paletted, nrgba := s.gray, s.rgba, s.paletted, s.nrgba
+	x0, dx, n := s.x0, s.dx, s.n
+	if s.rows != nil {
+		// Read the row into an image of its own. The buffer is not kept
+		// in the scanner, which is shared by concurrent workers.
+		b = image.Rect(b.Min.X, y, b.Max.X, y+1)
+		rgba = &image.RGBA{Pix: make([]uint8, 4*b.Dx()), Stride: 4 * b.Dx(), Rect: b}
+		s.rows.ReadRow(y, rgba.Pix)
+		m = rgba
+	}
+	i := 1
+	switch s.cb {
+	case cbG8:
+		if gray != nil {
+			offset := (y-b.Min.Y)*gray.Stride + x0 - b.Min.X
+			if dx == 1 {
+				copy(cr0[1:], gray.Pix[offset:offset+n])
 			} else {
-				for x := b.Min.X; x < b.Max.X; x++ {
-					c := color.GrayModel.Convert(m.At(x, y)).(color.Gray)
-					cr[0][i] = c.Y
-					i++
+				for j := 0; j < n; j++ {
+					cr0[1+j] = gray.Pix[offset+j*dx]
 				}
 			}
-		case cbTC8:
-			// We have previously verified that the alpha value is fully opaque.
-			cr0 := cr[0]
-			stride, pix := 0, []byte(nil)
-			if rgba != nil {
-				stride, pix = rgba.Stride, rgba.Pix
-			} else if nrgba != nil {
-				stride, pix = nrgba.Stride, nrgba.Pix
+		} else {
+			for x := x0; x < b.Max.X; x += dx {
+				c := color.GrayModel.Convert(m.At(x, y)).(color.Gray)
+				cr0[i] = c.Y
+				i++
 			}
-			if stride != 0 {
-				j0 := (y - b.Min.Y) * stride
-				j1 := j0 + b.Dx()*4
-				for j := j0; j < j1; j += 4 {
-					cr0[i+0] = pix[j+0]
-					cr0[i+1] = pix[j+1]
-					cr0[i+2] = pix[j+2]
-					i += 3
-				}
-			} else {
-				for x := b.Min.X; x < b.Max.X; x++ {
-					r, g, b, _ := m.At(x, y).RGBA()
-					cr0[i+0] = uint8(r >> 8)
-					cr0[i+1] = uint8(g >> 8)
-					cr0[i+2] = uint8(b >> 8)
-					i += 3
-				}
+		}
+	case cbTC8:
+		// We have previously verified that the alpha value is fully opaque.
+		stride, pix := 0, []byte(nil)
+		if rgba != nil {
+			stride, pix = rgba.Stride, rgba.Pix
+		} else if nrgba != nil {
+			stride, pix = nrgba.Stride, nrgba.Pix
+		}
+		if stride != 0 {
+			j0 := (y-b.Min.Y)*stride + (x0-b.Min.X)*4
+			j1 := j0 + n*4*dx
+			for j := j0; j < j1; j += 4 * dx {
+				cr0[i+0] = pix[j+0]
+				cr0[i+1] = pix[j+1]
+				cr0[i+2] = pix[j+2]
+				i += 3
 			}
-		case cbP8:
-			if paletted != nil {
-				offset := (y - b.Min.Y) * paletted.Stride
-				copy(cr[0][1:], paletted.Pix[offset:offset+b.Dx()])
-			} else {
-				pi := m.(image.PalettedImage)
-				for x := b.Min.X; x < b.Max.X; x++ {
-					cr[0][i] = pi.ColorIndexAt(x, y)
-					i += 1
-				}
+		} else {
+			for x := x0; x < b.Max.X; x += dx {
+				r, g, b, _ := m.At(x, y).RGBA()
+				cr0[i+0] = uint8(r >> 8)
+				cr0[i+1] = uint8(g >> 8)
+				cr0[i+2] = uint8(b >> 8)
+				i += 3
 			}
-		case cbTCA8:
-			if nrgba != nil {
-				offset := (y - b.Min.Y) * nrgba.Stride
-				copy(cr[0][1:], nrgba.Pix[offset:offset+b.Dx()*4])
+		}
+	case cbP8:
+		if paletted != nil {
+			offset := (y-b.Min.Y)*paletted.Stride + x0 - b.Min.X
+			if dx == 1 {
+				copy(cr0[1:], paletted.Pix[offset:offset+n])
 			} else {
-				// Convert from image.Image (which is alpha-premultiplied) to PNG's non-alpha-premultiplied.
-				for x := b.Min.X; x < b.Max.X; x++ {
-					c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
-					cr[0][i+0] = c.R
-					cr[0][i+1] = c.G
-					cr[0][i+2] = c.B
-					cr[0][i+3] = c.A
-					i += 4
+				for j := 0; j < n; j++ {
+					cr0[1+j] = paletted.Pix[offset+j*dx]
 				}
 			}
-		case cbG16:
-			for x := b.Min.X; x < b.Max.X; x++ {
-				c := color.Gray16Model.Convert(m.At(x, y)).(color.Gray16)
-				cr[0][i+0] = uint8(c.Y >> 8)
-				cr[0][i+1] = uint8(c.Y)
-				i += 2
+		} else {
+			pi := m.(image.PalettedImage)
+			for x := x0; x < b.Max.X; x += dx {
+				cr0[i] = pi.ColorIndexAt(x, y)
+				i += 1
 			}
-		case cbTC16:
-			// We have previously verified that the alpha value is fully opaque.
-			for x := b.Min.X; x < b.Max.X; x++ {
-				r, g, b, _ := m.At(x, y).RGBA()
-				cr[0][i+0] = uint8(r >> 8)
-				cr[0][i+1] = uint8(r)
-				cr[0][i+2] = uint8(g >> 8)
-				cr[0][i+3] = uint8(g)
-				cr[0][i+4] = uint8(b >> 8)
-				cr[0][i+5] = uint8(b)
-				i += 6
+		}
+	case cbTCA8:
+		if nrgba != nil {
+			offset := (y-b.Min.Y)*nrgba.Stride + (x0-b.Min.X)*4
+			if dx == 1 {
+				copy(cr0[1:], nrgba.Pix[offset:offset+n*4])
+			} else {
+				for j := 0; j < n; j++ {
+					copy(cr0[1+j*4:1+j*4+4], nrgba.Pix[offset+j*4*dx:])
+				}
 			}
-		case cbTCA16:
+		} else {
 			// Convert from image.Image (which is alpha-premultiplied) to PNG's non-alpha-premultiplied.
-			for x := b.Min.X; x < b.Max.X; x++ {
-				c := color.NRGBA64Model.Convert(m.At(x, y)).(color.NRGBA64)
-				cr[0][i+0] = uint8(c.R >> 8)
-				cr[0][i+1] = uint8(c.R)
-				cr[0][i+2] = uint8(c.G >> 8)
-				cr[0][i+3] = uint8(c.G)
-				cr[0][i+4] = uint8(c.B >> 8)
-				cr[0][i+5] = uint8(c.B)
-				cr[0][i+6] = uint8(c.A >> 8)
-				cr[0][i+7] = uint8(c.A)
-				i += 8
+			for x := x0; x < b.Max.X; x += dx {
+				c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+				cr0[i+0] = c.R
+				cr0[i+1] = c.G
+				cr0[i+2] = c.B
+				cr0[i+3] = c.A
+				i += 4
 			}
 		}
-
-		// Apply the filter.
-		// Skip filter for NoCompression and paletted images (cbP8) as
-		// "filters are rarely useful on palette images" and will result
-		// in larger files (see http://www.libpng.org/pub/png/book/chapter09.html).
-		f := ftNone
-		if level != zlib.NoCompression && cb != cbP8 {
-			f = filter(&cr, pr, bpp)
+	case cbGA8:
+		// Convert from image.Image (which is alpha-premultiplied) to PNG's non-alpha-premultiplied.
+		for x := x0; x < b.Max.X; x += dx {
+			c := color.NRGBA64Model.Convert(m.At(x, y)).(color.NRGBA64)
+			cr0[i+0] = uint8(luminance(c) >> 8)
+			cr0[i+1] = uint8(c.A >> 8)
+			i += 2
+		}
+	case cbG16:
+		for x := x0; x < b.Max.X; x += dx {
+			c := color.Gray16Model.Convert(m.At(x, y)).(color.Gray16)
+			cr0[i+0] = uint8(c.Y >> 8)
+			cr0[i+1] = uint8(c.Y)
+			i += 2
+		}
+	case cbGA16:
+		// Convert from image.Image (which is alpha-premultiplied) to PNG's non-alpha-premultiplied.
+		for x := x0; x < b.Max.X; x += dx {
+			c := color.NRGBA64Model.Convert(m.At(x, y)).(color.NRGBA64)
+			l := luminance(c)
+			cr0[i+0] = uint8(l >> 8)
+			cr0[i+1] = uint8(l)
+			cr0[i+2] = uint8(c.A >> 8)
+			cr0[i+3] = uint8(c.A)
+			i += 4
 		}
+	case cbTC16:
+		// We have previously verified that the alpha value is fully opaque.
+		for x := x0; x < b.Max.X; x += dx {
+			r, g, b, _ := m.At(x, y).RGBA()
+			cr0[i+0] = uint8(r >> 8)
+			cr0[i+1] = uint8(r)
+			cr0[i+2] = uint8(g >> 8)
+			cr0[i+3] = uint8(g)
+			cr0[i+4] = uint8(b >> 8)
+			cr0[i+5] = uint8(b)
+			i += 6
+		}
+	case cbTCA16:
+		// Convert from image.Image (which is alpha-premultiplied) to PNG's non-alpha-premultiplied.
+		for x := x0; x < b.Max.X; x += dx {
+			c := color.NRGBA64Model.Convert(m.At(x, y)).(color.NRGBA64)
+			cr0[i+0] = uint8(c.R >> 8)
+			cr0[i+1] = uint8(c.R)
+			cr0[i+2] = uint8(c.G >> 8)
+			cr0[i+3] = uint8(c.G)
+			cr0[i+4] = uint8(c.B >> 8)
+			cr0[i+5] = uint8(c.B)
+			cr0[i+6] = uint8(c.A >> 8)
+			cr0[i+7] = uint8(c.A)
+			i += 8
+		}
+	}
+}
 
-		// Write the compressed bytes.
-		if _, err := zw.Write(cr[f]); err != nil {
-			return err
+// Returns the luminance of c with the weights used by color.Gray16Model.
+func luminance(c color.NRGBA64) uint16 {
+	r, g, b := uint32(c.R), uint32(c.G), uint32(c.B)
+	return uint16((19595*r + 38470*g + 7471*b + 1<<15) >> 16)
+}
+
+// A scanPass is the reduced image of one Adam7 pass, or the whole image
+// when it is not interlaced. Row k of the pass is row y0+k*dy of the image.
+type scanPass struct {
+	s            *scanner
+	y0, dy, rows int
+}
+
+// Returns the passes to write, skipping the Adam7 passes that are empty.
+func scanPasses(m image.Image, cb int, interlace bool) []scanPass {
+	b := m.Bounds()
+	if !interlace {
+		return []scanPass{{newScanner(m, cb), b.Min.Y, 1, b.Dy()}}
+	}
+	var passes []scanPass
+	for _, p := range interlacing {
+		s := newScanner(m, cb)
+		// Add the multiplication factor and subtract one, effectively rounding up.
+		s.x0 = b.Min.X + p.xOffset
+		s.dx = p.xFactor
+		s.n = (b.Dx() - p.xOffset + p.xFactor - 1) / p.xFactor
+		rows := (b.Dy() - p.yOffset + p.yFactor - 1) / p.yFactor
+		if s.n <= 0 || rows <= 0 {
+			continue
 		}
+		passes = append(passes, scanPass{s, b.Min.Y + p.yOffset, p.yFactor, rows})
+	}
+	return passes
+}
+
+// A rowFilter filters the rows of a scanline, keeping the buffers between rows.
+type rowFilter struct {
+	cb       int
+	bpp      int
+	level    int
+	strategy FilterStrategy
+
+	// cr[*] and pr are the bytes for the current and previous row.
+	// cr[0] is unfiltered (or equivalently, filtered with the ftNone filter).
+	// cr[ft], for non-zero filter types ft, are buffers for transforming cr[0] under the
+	// other PNG filter types. These buffers are allocated once and re-used for each row.
+	// The +1 is for the per-row filter type, which is at cr[*][0].
+	cr [nFilter][]uint8
+	pr []uint8
+}
 
-		// The current row for y is the previous row for y+1.
-		pr, cr[0] = cr[0], pr
+func newRowFilter(width, cb, level int, strategy FilterStrategy) *rowFilter {
+	f := &rowFilter{cb: cb, bpp: bytesPerPixel(cb), level: level, strategy: strategy}
+	for i := range f.cr {
+		f.cr[i] = make([]uint8, 1+f.bpp*width)
+		f.cr[i][0] = uint8(i)
+	}
+	f.pr = make([]uint8, 1+f.bpp*width)
+	return f
+}
+
+// Filters the row in cr[0] and returns the filtered bytes. The row
+// becomes the previous row of the next call.
+func (rf *rowFilter) filter() []uint8 {
+	// Apply the filter.
+	// Skip filter for NoCompression and paletted images (cbP8) as
+	// "filters are rarely useful on palette images" and will result
+	// in larger files (see http://www.libpng.org/pub/png/book/chapter09.html).
+	// An explicitly chosen filter is always applied.
+	f := ftNone
+	switch {
+	case rf.strategy >= FilterNone && rf.strategy <= FilterPaeth:
+		f = int(rf.strategy - FilterNone)
+		applyFilter(&rf.cr, rf.pr, rf.bpp, f)
+	case rf.level == zlib.NoCompression || rf.cb == cbP8:
+	case rf.strategy == FilterEntropy:
+		f = filterEntropy(&rf.cr, rf.pr, rf.bpp)
+	default:
+		f = filter(&rf.cr, rf.pr, rf.bpp)
+	}
+	out := rf.cr[f]
+
+	// The current row for y is the previous row for y+1.
+	rf.pr, rf.cr[0] = rf.cr[0], rf.pr
+	return out
+}
+
+func writeImage(w io.Writer, m image.Image, cb int, level int, strategy FilterStrategy, interlace bool) error {
+	zw, err := zlib.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	defer zw.Close()
+
+	for _, p := range scanPasses(m, cb, interlace) {
+		// Every pass is filtered as a separate image.
+		rf := newRowFilter(p.s.n, cb, level, strategy)
+		for k := 0; k < p.rows; k++ {
+			p.s.row(p.y0+k*p.dy, rf.cr[0])
+
+			// Write the compressed bytes.
+			if _, err := zw.Write(rf.filter()); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
+// Minimum number of uncompressed bytes in a segment compressed by
+// writeImageParallel. Every segment starts with an empty dictionary,
+// so small segments compress worse.
+const minSegmentSize = 1 << 18
+
+// MaxWorkers is the largest number of goroutines an Encoder compresses
+// the image data with.
+const MaxWorkers = 256
+
+// A segment is a deflated run of rows k0 to k1 of a pass.
+type segment struct {
+	pass   *scanPass
+	k0, k1 int
+	data   []byte
+	adler  uint32
+	n      int
+	err    error
+	done   chan struct{}
+}
+
+// Compresses the rows of the segment with a sync flush, or closes the
+// deflate stream if it is the last segment.
+func (sg *segment) deflate(cb, level int, strategy FilterStrategy, last bool) {
+	defer close(sg.done)
+	p := sg.pass
+	rf := newRowFilter(p.s.n, cb, level, strategy)
+	if sg.k0 > 0 {
+		// Filters depend on the row above the segment.
+		p.s.row(p.y0+(sg.k0-1)*p.dy, rf.cr[0])
+		rf.pr, rf.cr[0] = rf.cr[0], rf.pr
+	}
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		sg.err = err
+		return
+	}
+	ad := adler32.New()
+	for k := sg.k0; k < sg.k1; k++ {
+		p.s.row(p.y0+k*p.dy, rf.cr[0])
+		row := rf.filter()
+		ad.Write(row)
+		sg.n += len(row)
+		if _, err := fw.Write(row); err != nil {
+			sg.err = err
+			return
+		}
+	}
+	if last {
+		sg.err = fw.Close()
+	} else {
+		sg.err = fw.Flush()
+	}
+	sg.data, sg.adler = buf.Bytes(), ad.Sum32()
+}
+
+// Combines the adler-32 checksums of two byte sequences, where n2 is
+// the length of the second one.
+func adler32Combine(a1, a2 uint32, n2 int) uint32 {
+	const mod = 65521
+	rem := uint64(n2 % mod)
+	s1 := uint64(a1 & 0xffff)
+	s2 := rem * s1 % mod
+	s1 += uint64(a2&0xffff) + mod - 1
+	s2 += uint64(a1>>16) + uint64(a2>>16) + mod - rem
+	s1 %= mod
+	s2 %= mod
+	return uint32(s2<<16 | s1)
+}
+
+// Like writeImage, but splits the rows into segments that are filtered and
+// deflated concurrently by the given number of workers. The segments are
+// joined into a single zlib stream like pigz does.
+func writeImageParallel(w io.Writer, m image.Image, cb int, level int, strategy FilterStrategy, interlace bool, workers int) error {
+	passes := scanPasses(m, cb, interlace)
+	bpp := bytesPerPixel(cb)
+	if workers > MaxWorkers {
+		workers = MaxWorkers
+	}
+
+	// Aim for four segments per worker.
+	size := 0
+	for _, p := range passes {
+		size += (1 + bpp*p.s.n) * p.rows
+	}
+	size /= 4 * workers
+	if size < minSegmentSize {
+		size = minSegmentSize
+	}
+
+	var segments []*segment
+	for i := range passes {
+		p := &passes[i]
+		rows := (size + bpp*p.s.n) / (1 + bpp*p.s.n)
+		for k := 0; k < p.rows; k += rows {
+			k1 := k + rows
+			if k1 > p.rows {
+				k1 = p.rows
+			}
+			segments = append(segments, &segment{pass: p, k0: k, k1: k1, done: make(chan struct{})})
+		}
+	}
+
+	if workers > len(segments) {
+		workers = len(segments)
+	}
+
+	// At most two segments per worker are compressed or waiting to be
+	// written at a time, so that the whole image is not held in memory.
+	// A slot is taken before a segment is queued and given back once it
+	// has been written.
+	slots := make(chan struct{}, 2*workers)
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range segments {
+			slots <- struct{}{}
+			queue <- i
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for i := range queue {
+				segments[i].deflate(cb, level, strategy, i == len(segments)-1)
+			}
+		}()
+	}
+
+	// The zlib header has the same compression level bits as zlib.Writer.
+	var header [2]byte
+	header[0] = 0x78
+	switch level {
+	case zlib.NoCompression, zlib.BestSpeed:
+		header[1] = 0 << 6
+	case 2, 3, 4, 5:
+		header[1] = 1 << 6
+	case 6, zlib.DefaultCompression:
+		header[1] = 2 << 6
+	default:
+		header[1] = 3 << 6
+	}
+	header[1] += uint8(31 - (uint16(header[0])<<8+uint16(header[1]))%31)
+
+	// Write the segments in order as they finish, but wait for all of
+	// them so that no worker is left blocked.
+	var adler uint32
+	_, err := w.Write(header[:])
+	for i, sg := range segments {
+		<-sg.done
+		if err == nil {
+			err = sg.err
+		}
+		if err == nil {
+			if i == 0 {
+				adler = sg.adler
+			} else {
+				adler = adler32Combine(adler, sg.adler, sg.n)
+			}
+			_, err = w.Write(sg.data)
+		}
+		sg.data = nil
+		<-slots
+	}
+	if err != nil {
+		return err
+	}
+	var footer [4]byte
+	writeUint32(footer[:], adler)
+	_, err = w.Write(footer[:])
+	return err
+}
+
 // Write the actual image data to one or more IDAT chunks.
 func (e *encoder) writeIDATs() {
 	if e.err != nil {
@@ -453,7 +967,11 @@ func (e *encoder) writeIDATs() {
 	}
 	var bw *bufio.Writer
 	bw = bufio.NewWriterSize(e, 1<<15)
-	e.err = writeImage(bw, e.m, e.cb, levelToZlib(e.enc.CompressionLevel))
+	if e.enc.Workers > 1 {
+		e.err = writeImageParallel(bw, e.m, e.cb, levelToZlib(e.enc.CompressionLevel), e.enc.Filter, e.enc.Interlace, e.enc.Workers)
+	} else {
+		e.err = writeImage(bw, e.m, e.cb, levelToZlib(e.enc.CompressionLevel), e.enc.Filter, e.enc.Interlace)
+	}
 	if e.err != nil {
 		return
 	}
@@ -473,6 +991,9 @@ func levelToZlib(l CompressionLevel) int {
 	case BestCompression:
 		return zlib.BestCompression
 	default:
+		if l > 0 && l <= zlib.BestCompression {
+			return int(l)
+		}
 		return zlib.DefaultCompression
 	}
 }
@@ -495,17 +1016,55 @@ func (enc *Encoder) Encode(w io.Writer, m image.Image, gAMA uint32) error {
 	if mw <= 0 || mh <= 0 || mw >= 1<<32 || mh >= 1<<32 {
 		return FormatError("invalid image size: " + strconv.FormatInt(mw, 10) + "x" + strconv.FormatInt(mh, 10))
 	}
-
-	var e encoder
-	e.enc = enc
-	e.w = w
-	e.m = m
+	if enc.CompressionLevel < BestCompression || enc.CompressionLevel > zlib.BestCompression {
+		return UnsupportedError("compression level " + strconv.Itoa(int(enc.CompressionLevel)))
+	}
+	if enc.Filter < FilterAdaptive || enc.Filter > FilterEntropy {
+		return UnsupportedError("filter strategy " + enc.Filter.String())
+	}
+	for _, c := range enc.ColorChunks {
+		switch c.Type {
+		case "gAMA", "cHRM", "sRGB", "iCCP":
+		default:
+			return UnsupportedError("colour chunk " + c.Type)
+		}
+		if err := checkLength(c); err != nil {
+			return err
+		}
+	}
+	for _, c := range enc.Chunks {
+		if err := checkChunk(c); err != nil {
+			return err
+		}
+	}
 
 	var pal color.Palette
 	// cbP8 encoding needs PalettedImage's ColorIndexAt method.
 	if _, ok := m.(image.PalettedImage); ok {
 		pal, _ = m.ColorModel().(color.Palette)
 	}
+	if pal == nil && enc.Quantizer != nil {
+		pal = enc.Quantizer.Quantize(make(color.Palette, 0, 256), m)
+		if len(pal) == 0 {
+			return FormatError("quantizer produced an empty palette")
+		}
+		if len(pal) > 256 {
+			pal = pal[:256]
+		}
+		drawer := enc.Drawer
+		if drawer == nil {
+			drawer = draw.FloydSteinberg
+		}
+		pm := image.NewPaletted(m.Bounds(), pal)
+		drawer.Draw(pm, pm.Bounds(), m, m.Bounds().Min)
+		m = pm
+	}
+
+	var e encoder
+	e.enc = enc
+	e.w = w
+	e.m = m
+
 	if pal != nil {
 		e.cb = cbP8
 	} else {
@@ -515,15 +1074,25 @@ func (enc *Encoder) Encode(w io.Writer, m image.Image, gAMA uint32) error {
 		case color.Gray16Model:
 			e.cb = cbG16
 		case color.RGBAModel, color.NRGBAModel, color.AlphaModel:
-			if opaque(m) {
+			switch isOpaque := opaque(m); {
+			case enc.Grayscale && isOpaque:
+				e.cb = cbG8
+			case enc.Grayscale:
+				e.cb = cbGA8
+			case isOpaque:
 				e.cb = cbTC8
-			} else {
+			default:
 				e.cb = cbTCA8
 			}
 		default:
-			if opaque(m) {
+			switch isOpaque := opaque(m); {
+			case enc.Grayscale && isOpaque:
+				e.cb = cbG16
+			case enc.Grayscale:
+				e.cb = cbGA16
+			case isOpaque:
 				e.cb = cbTC16
-			} else {
+			default:
 				e.cb = cbTCA16
 			}
 		}
@@ -531,11 +1100,19 @@ func (enc *Encoder) Encode(w io.Writer, m image.Image, gAMA uint32) error {
 
 	_, e.err = io.WriteString(w, pngHeader)
 	e.writeIHDR()
-	e.writeGAMA(gAMA)
+	if enc.ColorChunks == nil {
+		e.writeGAMA(gAMA)
+	}
+	for _, c := range enc.ColorChunks {
+		e.writeChunk(c.Data, c.Type)
+	}
+	e.writeChunks(BeforePLTE)
 	if pal != nil {
 		e.writePLTEAndTRNS(pal)
 	}
+	e.writeChunks(AfterPLTE)
 	e.writeIDATs()
+	e.writeChunks(AfterIDAT)
 	e.writeIEND()
 	return e.err
 }
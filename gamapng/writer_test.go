@@ -0,0 +1,103 @@
+package gamapng
+
+import (
+	"bytes"
+	"fmt"
+	"hash/adler32"
+	"image"
+	"image/color/palette"
+	"math/rand"
+	"testing"
+)
+
+// noisy returns a w by h image filled with random pixels.
+func noisy(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rand.New(rand.NewSource(1)).Read(m.Pix)
+	return m
+}
+
+// roundTrip encodes m with enc and decodes the result.
+func roundTrip(t *testing.T, enc *Encoder, m image.Image) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, m, 45455); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// samePixels reports the first pixel where got and want differ.
+func samePixels(t *testing.T, name string, got, want image.Image) {
+	t.Helper()
+	if got.Bounds() != want.Bounds() {
+		t.Errorf("%s: bounds %v, want %v", name, got.Bounds(), want.Bounds())
+		return
+	}
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, a1 := got.At(x, y).RGBA()
+			r2, g2, b2, a2 := want.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, x, y, got.At(x, y), want.At(x, y))
+				return
+			}
+		}
+	}
+}
+
+func TestAdler32Combine(t *testing.T) {
+	data := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(data)
+	// Lengths around the modulus of 65521 and empty sequences.
+	for _, split := range [][2]int{{0, 10}, {10, 0}, {1, 1}, {100, 65521}, {65521, 65522}, {70000, 130000}, {3, 199997}} {
+		a, b := data[:split[0]], data[split[0]:split[0]+split[1]]
+		got := adler32Combine(adler32.Checksum(a), adler32.Checksum(b), len(b))
+		want := adler32.Checksum(data[:split[0]+split[1]])
+		if got != want {
+			t.Errorf("adler32Combine of %d and %d bytes is %08x, want %08x", len(a), len(b), got, want)
+		}
+	}
+}
+
+func TestParallelEncode(t *testing.T) {
+	// The large image is split into several segments, the small one fits
+	// in a single segment.
+	for _, m := range []*image.NRGBA{noisy(600, 400), noisy(30, 20)} {
+		for _, interlace := range []bool{false, true} {
+			serial := roundTrip(t, &Encoder{Interlace: interlace}, m)
+			samePixels(t, "serial", serial, m)
+			for _, workers := range []int{2, 3, 8, MaxWorkers + 1} {
+				name := fmt.Sprintf("%v interlace %v workers %d", m.Bounds().Size(), interlace, workers)
+				samePixels(t, name, roundTrip(t, &Encoder{Interlace: interlace, Workers: workers}, m), serial)
+			}
+		}
+	}
+}
+
+func TestInterlaceRoundTrip(t *testing.T) {
+	// Sizes where some of the seven passes are empty, and one where none is.
+	for _, size := range []image.Point{{1, 1}, {3, 5}, {9, 9}} {
+		rgba := noisy(size.X, size.Y)
+		gray := image.NewGray(rgba.Rect)
+		rand.New(rand.NewSource(2)).Read(gray.Pix)
+		paletted := image.NewPaletted(rgba.Rect, palette.WebSafe)
+		for i := range paletted.Pix {
+			paletted.Pix[i] = uint8(i * 7 % len(palette.WebSafe))
+		}
+		// Four colours are written with two bits per pixel.
+		small := image.NewPaletted(rgba.Rect, palette.Plan9[:4])
+		for i := range small.Pix {
+			small.Pix[i] = uint8(i * 3 % 4)
+		}
+		for name, m := range map[string]image.Image{"truecolour": rgba, "gray": gray, "paletted": paletted, "2 bit": small} {
+			name := fmt.Sprintf("%s %v", name, size)
+			samePixels(t, name, roundTrip(t, &Encoder{Interlace: true}, m), m)
+		}
+	}
+}
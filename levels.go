@@ -0,0 +1,259 @@
+package dualpng
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Range is a range of colour values that a channel is leveled into.
+type Range struct {
+	Low  uint8
+	High uint8
+
+	// Gamma bends the mapping into the range along the curve v^Gamma,
+	// where v is the source value between 0 and 1.
+	// Zero and one map linearly.
+	Gamma float64
+
+	// Low16 and High16 are the bounds of the range in the 16 bit pipeline.
+	// If both are zero Low and High are scaled to 16 bits instead.
+	Low16  uint16
+	High16 uint16
+}
+
+// NewRange creates a range after checking that low and high are
+// colour values, that low is not greater than high and that the
+// curve gamma is not negative.
+//    low   : lowest value in range
+//    high  : highest value in range
+//    gamma : curve inside the range, zero for linear
+func NewRange(low, high int, gamma float64) (Range, error) {
+	r := Range{Low: uint8(low), High: uint8(high), Gamma: gamma}
+	if low < 0 || low > 255 || high < 0 || high > 255 {
+		return r, errors.New("Invalid range: values must be between 0 and 255")
+	}
+	return r, r.Validate()
+}
+
+// Validate reports whether the range is usable for leveling.
+func (r Range) Validate() error {
+	if low, high := r.bounds16(); r.Low > r.High || low > high {
+		return errors.New("Invalid range: low is greater than high")
+	}
+	if r.Gamma < 0 || math.IsNaN(r.Gamma) || math.IsInf(r.Gamma, 0) {
+		return errors.New("Invalid range: gamma must be a positive number")
+	}
+	return nil
+}
+
+// ParseRange parses a range of the form "low-high" or "low-high@gamma".
+// A single number "high" is the same as "0-high".
+// Values with decimals, such as "240.5-255", set the 16 bit bounds
+// of the range more finely than the 8 bit ones.
+func ParseRange(txt string) (Range, error) {
+	var (
+		gamma   float64
+		from    = "0"
+		to      string
+		invalid = errors.New("Invalid range: " + txt)
+		err     error
+	)
+	if i := strings.IndexByte(txt, '@'); i >= 0 {
+		gamma, err = strconv.ParseFloat(strings.TrimSpace(txt[i+1:]), 64)
+		if err != nil || math.IsNaN(gamma) || math.IsInf(gamma, 0) {
+			return Range{}, invalid
+		}
+		txt = txt[:i]
+	}
+	numbers := strings.Split(txt, "-")
+	switch len(numbers) {
+	case 1:
+		to = numbers[0]
+	case 2:
+		from, to = numbers[0], numbers[1]
+	default:
+		return Range{}, invalid
+	}
+	low, err := strconv.ParseFloat(strings.TrimSpace(from), 64)
+	if err != nil {
+		return Range{}, invalid
+	}
+	high, err := strconv.ParseFloat(strings.TrimSpace(to), 64)
+	if err != nil {
+		return Range{}, invalid
+	}
+	// NaN fails every comparison, so it is ruled out explicitly.
+	if math.IsNaN(low) || math.IsNaN(high) || low < 0 || low > 255 || high < 0 || high > 255 {
+		return Range{}, errors.New("Invalid range: values must be between 0 and 255")
+	}
+	if low == math.Trunc(low) && high == math.Trunc(high) {
+		return NewRange(int(low), int(high), gamma)
+	}
+	r := Range{
+		Low:    uint8(math.Round(low)),
+		High:   uint8(math.Round(high)),
+		Gamma:  gamma,
+		Low16:  uint16(math.Round(low * 257)),
+		High16: uint16(math.Round(high * 257)),
+	}
+	return r, r.Validate()
+}
+
+// String returns the range in the form accepted by ParseRange.
+func (r Range) String() string {
+	s := strconv.Itoa(int(r.Low)) + "-" + strconv.Itoa(int(r.High))
+	if r.Low16 != 0 || r.High16 != 0 {
+		s = format16(r.Low16) + "-" + format16(r.High16)
+	}
+	if r.Gamma != 0 && r.Gamma != 1 {
+		s += "@" + strconv.FormatFloat(r.Gamma, 'g', -1, 64)
+	}
+	return s
+}
+
+// format16 formats a 16 bit value on the 8 bit scale of ParseRange,
+// with as many decimals as it takes to parse back to the same value.
+func format16(v uint16) string {
+	if v%257 == 0 {
+		return strconv.Itoa(int(v / 257))
+	}
+	return strconv.FormatFloat(math.Round(float64(v)/257*1000)/1000, 'f', -1, 64)
+}
+
+// bounds16 returns the bounds of the range in the 16 bit pipeline.
+func (r Range) bounds16() (low, high uint16) {
+	if r.Low16 == 0 && r.High16 == 0 {
+		return uint16(r.Low) * 0x101, uint16(r.High) * 0x101
+	}
+	return r.Low16, r.High16
+}
+
+// lut16 returns the table mapping 16 bit values into the range.
+func (r Range) lut16() []uint16 {
+	t := make([]uint16, 1<<16)
+	low, high := r.bounds16()
+	span := float64(int(high) - int(low))
+	for n := range t {
+		v := float64(n) / 0xffff
+		if r.Gamma != 0 && r.Gamma != 1 {
+			v = math.Pow(v, r.Gamma)
+		}
+		t[n] = uint16(int(v*span) + int(low))
+	}
+	return t
+}
+
+// lut returns the table mapping 8 bit values into the range.
+func (r Range) lut() *[256]uint8 {
+	var t [256]uint8
+	span := float64(int(r.High) - int(r.Low))
+	for n := range t {
+		v := float64(n) / 255
+		if r.Gamma != 0 && r.Gamma != 1 {
+			v = math.Pow(v, r.Gamma)
+		}
+		t[n] = uint8(int(v*span) + int(r.Low))
+	}
+	return &t
+}
+
+// Levels holds a range for each colour channel.
+type Levels struct {
+	R, G, B Range
+}
+
+// UniformLevels returns levels that use the same range for every channel.
+func UniformLevels(r Range) Levels {
+	return Levels{r, r, r}
+}
+
+// ParseLevels parses either a single range used for every channel, or three
+// comma separated ranges for the red, green and blue channels.
+// See ParseRange for the form of a range. Ex "230-255" or "230-255@0.5,220-255,0-255"
+func ParseLevels(txt string) (Levels, error) {
+	parts := strings.Split(txt, ",")
+	if len(parts) != 1 && len(parts) != 3 {
+		return Levels{}, errors.New("Invalid levels, expected one or three ranges: " + txt)
+	}
+	var ranges [3]Range
+	for i, part := range parts {
+		r, err := ParseRange(part)
+		if err != nil {
+			return Levels{}, err
+		}
+		ranges[i] = r
+	}
+	if len(parts) == 1 {
+		return UniformLevels(ranges[0]), nil
+	}
+	return Levels{ranges[0], ranges[1], ranges[2]}, nil
+}
+
+// Validate reports whether every channel's range is usable for leveling.
+func (l Levels) Validate() error {
+	for _, r := range []Range{l.R, l.G, l.B} {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the levels in the form accepted by ParseLevels.
+func (l Levels) String() string {
+	if l.R == l.G && l.G == l.B {
+		return l.R.String()
+	}
+	return l.R.String() + "," + l.G.String() + "," + l.B.String()
+}
+
+// LevelChannels sets the values of each colour channel of the given image
+// to be within that channel's range.
+//     img    : Source image
+//     levels : Ranges for the red, green and blue channels
+func LevelChannels(img image.Image, levels Levels) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	lr, lg, lb := levels.R.lut(), levels.G.lut(), levels.B.lut()
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		row := make([]uint16, 4*b.Dx())
+		for y := y0; y < y1; y++ {
+			readRow(img, y, b.Min.X, b.Max.X, row)
+			pix := out.Pix[out.PixOffset(b.Min.X, y):]
+			for i := 0; i < len(row); i += 4 {
+				pix[i+0] = lr[row[i+0]>>8]
+				pix[i+1] = lg[row[i+1]>>8]
+				pix[i+2] = lb[row[i+2]>>8]
+				pix[i+3] = uint8(row[i+3] >> 8)
+			}
+		}
+	})
+	return out
+}
+
+// LevelChannels16 is LevelChannels for the 16 bit pipeline. The values
+// of each channel keep their 16 bit precision and are leveled into the
+// 16 bit bounds of that channel's range.
+//     img    : Source image
+//     levels : Ranges for the red, green and blue channels
+func LevelChannels16(img image.Image, levels Levels) *image.RGBA64 {
+	b := img.Bounds()
+	out := image.NewRGBA64(b)
+	lr, lg, lb := levels.R.lut16(), levels.G.lut16(), levels.B.lut16()
+	parallelRows(b.Min.Y, b.Max.Y, func(y0, y1 int) {
+		row := make([]uint16, 4*b.Dx())
+		for y := y0; y < y1; y++ {
+			readRow(img, y, b.Min.X, b.Max.X, row)
+			for i := 0; i < len(row); i += 4 {
+				row[i+0] = lr[row[i+0]]
+				row[i+1] = lg[row[i+1]]
+				row[i+2] = lb[row[i+2]]
+			}
+			writeRow64(out, y, row)
+		}
+	})
+	return out
+}
@@ -0,0 +1,51 @@
+package dualpng
+
+import "testing"
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		txt  string
+		want Range
+		ok   bool
+	}{
+		{"230", Range{Low: 0, High: 230}, true},
+		{"230-255", Range{Low: 230, High: 255}, true},
+		{"20-230@0.5", Range{Low: 20, High: 230, Gamma: 0.5}, true},
+		{" 10 - 20 ", Range{Low: 10, High: 20}, true},
+		{"240.5-255", Range{Low: 241, High: 255, Low16: 61809, High16: 65535}, true},
+		{"0.25-0.75@2", Range{Low: 0, High: 1, Gamma: 2, Low16: 64, High16: 193}, true},
+		{"254.9-254.95", Range{Low: 255, High: 255, Low16: 65509, High16: 65522}, true},
+
+		{"", Range{}, false},
+		{"1-2-3", Range{}, false},
+		{"a-b", Range{}, false},
+		{"20-10", Range{}, false},
+		{"20.5-10.5", Range{}, false},
+		{"0-256", Range{}, false},
+		{"0-255.5", Range{}, false},
+		{"NaN", Range{}, false},
+		{"NaN-255", Range{}, false},
+		{"0-NaN", Range{}, false},
+		{"Inf", Range{}, false},
+		{"0-+Inf", Range{}, false},
+		{"1e300", Range{}, false},
+		{"0-255@NaN", Range{}, false},
+		{"0-255@Inf", Range{}, false},
+		{"0-255@-1", Range{}, false},
+		{"0-255@", Range{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRange(tt.txt)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("ParseRange(%q) = %+v, want an error", tt.txt, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRange(%q): %v", tt.txt, err)
+		} else if got != tt.want {
+			t.Errorf("ParseRange(%q) = %+v, want %+v", tt.txt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package dualpng
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Necroforger/dualpng/gamapng"
+	"github.com/nfnt/resize"
+)
+
+// Mode selects how the two images are merged.
+type Mode string
+
+// Merge modes
+const (
+	// ModeGamma hides the second image in colours that only become visible
+	// when a viewer applies the gAMA chunk.
+	ModeGamma Mode = "gamma"
+
+	// ModeAlpha shows the first image on Background1 and the second
+	// image on Background2. See MergeAlpha.
+	ModeAlpha Mode = "alpha"
+
+	// ModeDither chooses every pixel from one of the images with error
+	// diffusion instead of a repeating mask. See MergeDiffused.
+	ModeDither Mode = "dither"
+)
+
+// ParseMode parses the name of a merge mode.
+func ParseMode(txt string) (Mode, error) {
+	switch m := Mode(txt); m {
+	case ModeGamma, ModeAlpha, ModeDither:
+		return m, nil
+	}
+	return "", errors.New("Invalid mode: " + txt)
+}
+
+// Options configures every step of creating a dual png.
+type Options struct {
+	// Width and Height to resize both images to. If only one is set
+	// the other is calculated to preserve the aspect ratio.
+	// If both are zero the images are not resized.
+	Width  uint
+	Height uint
+
+	// Filter is the resampling filter used when resizing.
+	Filter resize.InterpolationFunction
+
+	// Brightness1 and Brightness2 scale the brightness of each image.
+	// Zero leaves the image unchanged.
+	Brightness1 float64
+	Brightness2 float64
+
+	// Range1 and Range2 are the colour ranges each image is leveled into.
+	Range1 Levels
+	Range2 Levels
+
+	// Mode selects how the images are merged. The zero value is ModeGamma.
+	Mode Mode
+
+	// Background1 and Background2 are the backgrounds the first and
+	// second image are shown on in ModeAlpha.
+	Background1 color.RGBA
+	Background2 color.RGBA
+
+	// Diffusion is the error diffusion algorithm used in ModeDither.
+	// The zero value is FloydSteinberg.
+	Diffusion Diffusion
+
+	// Mask matrix used when merging. See MergeImages.
+	// In ModeDither only the mean weight of the mask is used.
+	Mask [][]float64
+
+	// Gamma is the gAMA value multiplied by 100,000.
+	Gamma uint32
+
+	// Encoder holds the png compression settings used by Encode.
+	Encoder gamapng.Encoder
+
+	// ColorChunks lists the colour chunks Encode writes, in order. See
+	// ParseColorChunks. If nil, the chunks of Encoder are written, which
+	// is a single gAMA chunk unless Encoder.ColorChunks is set.
+	ColorChunks []string
+
+	// ICCProfile is written in the iCCP chunk when ColorChunks has one.
+	ICCProfile *gamapng.ICCProfile
+
+	// EmbedParameters makes Encode write the options in an iTXt chunk,
+	// so that ReadOptions can read them back from the image.
+	EmbedParameters bool
+
+	// Palette is the number of colours, at most 256, that Encode reduces
+	// the image to with a RangeQuantizer. Zero writes a truecolour image
+	// unless Encoder has a Quantizer.
+	Palette int
+
+	// Depth is the bit depth, 8 or 16, images are processed and written
+	// with. Zero means 8. At 16 bits the colour ranges can fall between
+	// the 8 bit values, see Range.
+	Depth int
+
+	// Gray converts both images to their luminance before merging them,
+	// and makes Encode write a grayscale image, with alpha in ModeAlpha.
+	Gray bool
+}
+
+// DefaultOptions returns the options used by the dualpng command
+// when no flags are given.
+func DefaultOptions() Options {
+	return Options{
+		Filter: resize.Lanczos3,
+		Range1: UniformLevels(Range{Low: 0, High: 230}),
+		Range2: UniformLevels(Range{Low: 230, High: 255}),
+		Gamma:  2300,
+	}
+}
+
+// DefaultAlphaOptions returns options for ModeAlpha that show the first
+// image on white and the second image on black.
+// The gAMA value is neutral so that gamma correction does not alter the image.
+func DefaultAlphaOptions() Options {
+	return Options{
+		Filter:      resize.Lanczos3,
+		Range1:      UniformLevels(Range{Low: 128, High: 255}),
+		Range2:      UniformLevels(Range{Low: 0, High: 127}),
+		Gamma:       45455,
+		Mode:        ModeAlpha,
+		Background1: color.RGBA{0xff, 0xff, 0xff, 0xff},
+		Background2: color.RGBA{0x00, 0x00, 0x00, 0xff},
+	}
+}
+
+// Validate reports whether the options can be used to build an image.
+func (o Options) Validate() error {
+	if err := o.Range1.Validate(); err != nil {
+		return err
+	}
+	if err := o.Range2.Validate(); err != nil {
+		return err
+	}
+	switch o.Mode {
+	case "", ModeGamma:
+	case ModeAlpha:
+		if o.Background1 == o.Background2 {
+			return errors.New("Backgrounds must differ in alpha mode")
+		}
+	case ModeDither:
+		if o.Diffusion != "" {
+			if _, err := ParseDiffusion(string(o.Diffusion)); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("Invalid mode: " + string(o.Mode))
+	}
+	if o.Brightness1 < 0 || o.Brightness2 < 0 {
+		return errors.New("Brightness can not be negative")
+	}
+	if l := o.Encoder.CompressionLevel; l < gamapng.BestCompression || l > 9 {
+		return errors.New("Invalid compression level: " + strconv.Itoa(int(l)))
+	}
+	if f := o.Encoder.Filter; f < gamapng.FilterAdaptive || f > gamapng.FilterEntropy {
+		return errors.New("Invalid filter strategy: " + f.String())
+	}
+	if j := o.Encoder.Workers; j < 0 || j > gamapng.MaxWorkers {
+		return errors.New("Workers must be between 0 and " + strconv.Itoa(gamapng.MaxWorkers))
+	}
+	// Without colour chunks of its own Encode writes the gAMA chunk of Encoder.
+	gAMA := o.ColorChunks == nil && o.Encoder.ColorChunks == nil
+	for _, name := range o.ColorChunks {
+		switch name {
+		case "gAMA":
+			gAMA = true
+		case "sRGB", "cHRM":
+		case "iCCP":
+			if o.ICCProfile == nil {
+				return errors.New("iCCP chunk requires an ICC profile")
+			}
+		default:
+			return errors.New("Invalid colour chunk: " + name)
+		}
+	}
+	if gAMA && o.Gamma == 0 {
+		return errors.New("gAMA value must be greater than zero")
+	}
+	if o.Palette < 0 || o.Palette > 256 {
+		return errors.New("Palette must have between 0 and 256 colours, where 0 writes truecolour")
+	}
+	switch o.Depth {
+	case 0, 8:
+	case 16:
+		if o.Palette > 0 {
+			return errors.New("Palette output can not be 16 bit")
+		}
+	default:
+		return errors.New("Invalid bit depth: " + strconv.Itoa(o.Depth))
+	}
+	if o.Gray && o.Palette > 0 {
+		return errors.New("Palette output can not be gray")
+	}
+	if o.Mask != nil {
+		if len(o.Mask) == 0 || len(o.Mask[0]) == 0 {
+			return errors.New("Mask matrix is empty")
+		}
+		for _, row := range o.Mask {
+			if len(row) != len(o.Mask[0]) {
+				return errors.New("Mask matrix rows must have the same length")
+			}
+		}
+	}
+	return nil
+}
+
+// Build resizes, brightens, levels and merges img1 and img2
+// according to the options. Encode the result with Options.Encode.
+//    img1 : image visible when gamma correction is not applied
+//    img2 : image visible when gamma correction is applied
+//    o    : options
+func Build(img1, img2 image.Image, o Options) (image.Image, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	if o.Width > 0 || o.Height > 0 {
+		img1 = resize.Resize(o.Width, o.Height, img1, o.Filter)
+		img2 = resize.Resize(o.Width, o.Height, img2, o.Filter)
+	}
+
+	if o.Gray {
+		if o.Depth == 16 {
+			img1, img2 = Grayscale16(img1), Grayscale16(img2)
+		} else {
+			img1, img2 = Grayscale(img1), Grayscale(img2)
+		}
+	}
+
+	if o.Depth == 16 {
+		return build16(img1, img2, o)
+	}
+
+	if o.Brightness1 != 0 && o.Brightness1 != 1 {
+		img1 = ScaleBrightness(img1, o.Brightness1)
+	}
+	if o.Brightness2 != 0 && o.Brightness2 != 1 {
+		img2 = ScaleBrightness(img2, o.Brightness2)
+	}
+
+	img1 = LevelChannels(img1, o.Range1)
+	img2 = LevelChannels(img2, o.Range2)
+
+	switch o.Mode {
+	case ModeAlpha:
+		return MergeAlpha(img1, img2, o.Background1, o.Background2, o.Mask), nil
+	case ModeDither:
+		diffusion, density := o.diffusion()
+		return MergeDiffused(img1, img2, diffusion, density)
+	}
+	return MergeImages(img1, img2, o.Mask), nil
+}
+
+// build16 brightens, levels and merges resized images with 16 bits per channel.
+func build16(img1, img2 image.Image, o Options) (image.Image, error) {
+	if o.Brightness1 != 0 && o.Brightness1 != 1 {
+		img1 = ScaleBrightness16(img1, o.Brightness1)
+	}
+	if o.Brightness2 != 0 && o.Brightness2 != 1 {
+		img2 = ScaleBrightness16(img2, o.Brightness2)
+	}
+
+	img1 = LevelChannels16(img1, o.Range1)
+	img2 = LevelChannels16(img2, o.Range2)
+
+	switch o.Mode {
+	case ModeAlpha:
+		return MergeAlpha16(img1, img2, o.Background1, o.Background2, o.Mask), nil
+	case ModeDither:
+		diffusion, density := o.diffusion()
+		return MergeDiffused16(img1, img2, diffusion, density)
+	}
+	return MergeImages16(img1, img2, o.Mask), nil
+}
+
+// diffusion returns the error diffusion algorithm and density used in ModeDither.
+func (o Options) diffusion() (Diffusion, float64) {
+	diffusion, mask := o.Diffusion, o.Mask
+	if diffusion == "" {
+		diffusion = FloydSteinberg
+	}
+	if mask == nil {
+		mask = DefaultMask
+	}
+	return diffusion, maskDensity(mask)
+}
+
+// Encode encodes an image created by Build with the options' gAMA value
+// and encoder settings.
+func (o Options) Encode(w io.Writer, img image.Image) error {
+	enc := o.Encoder
+	if o.ColorChunks != nil {
+		// An empty list must stay non-nil, or the encoder writes gAMA.
+		enc.ColorChunks = []gamapng.Chunk{}
+		for _, name := range o.ColorChunks {
+			c, err := o.colorChunk(name)
+			if err != nil {
+				return err
+			}
+			enc.ColorChunks = append(enc.ColorChunks, c)
+		}
+	}
+	if o.Gamma == 0 && enc.ColorChunks == nil {
+		return errors.New("gAMA value must be greater than zero")
+	}
+	if o.EmbedParameters {
+		c, err := o.ParametersChunk()
+		if err != nil {
+			return err
+		}
+		enc.Chunks = append(append([]gamapng.Chunk(nil), enc.Chunks...), c)
+	}
+	if o.Palette > 0 {
+		q := RangeQuantizer{Range1: o.Range1, Range2: o.Range2, Colors: o.Palette}
+		enc.Quantizer, enc.Drawer = q, q
+	}
+	if o.Gray {
+		enc.Grayscale = true
+	}
+	return EncodeWith(w, img, o.Gamma, &enc)
+}
+
+// ParseColorChunks parses a comma separated list of the colour chunks to
+// write, in order. Each is one of gAMA, sRGB, cHRM or iCCP, in any case.
+// "none" writes no colour chunks and an empty list writes just gAMA.
+// For example "sRGB,gAMA" hides the gamma trick from decoders that give
+// sRGB priority over gAMA.
+func ParseColorChunks(txt string) ([]string, error) {
+	if txt == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(txt, "none") {
+		return []string{}, nil
+	}
+	var names []string
+	for _, name := range strings.Split(txt, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gama":
+			names = append(names, "gAMA")
+		case "srgb":
+			names = append(names, "sRGB")
+		case "chrm":
+			names = append(names, "cHRM")
+		case "iccp":
+			names = append(names, "iCCP")
+		default:
+			return nil, errors.New("Invalid colour chunk: " + name)
+		}
+	}
+	return names, nil
+}
+
+// colorChunk creates the colour chunk with the given name from the options.
+// sRGB chunks have the perceptual rendering intent and cHRM chunks the
+// chromaticities of sRGB.
+func (o Options) colorChunk(name string) (gamapng.Chunk, error) {
+	switch name {
+	case "gAMA":
+		if o.Gamma == 0 {
+			return gamapng.Chunk{}, errors.New("gAMA value must be greater than zero")
+		}
+		return gamapng.GAMAChunk(o.Gamma), nil
+	case "sRGB":
+		return gamapng.SRGBChunk(gamapng.Perceptual), nil
+	case "cHRM":
+		return gamapng.CHRMChunk(gamapng.SRGBChromaticities), nil
+	case "iCCP":
+		if o.ICCProfile == nil {
+			return gamapng.Chunk{}, errors.New("iCCP chunk requires an ICC profile")
+		}
+		return gamapng.ICCPChunk(*o.ICCProfile)
+	}
+	return gamapng.Chunk{}, errors.New("Invalid colour chunk: " + name)
+}
+
+// Apply sets the gAMA value and colour ranges of o to the solution.
+func (s Solution) Apply(o *Options) {
+	o.Gamma = s.Gamma
+	o.Range1 = UniformLevels(Range{Low: s.Range1Low, High: s.Range1High})
+	o.Range2 = UniformLevels(Range{Low: s.Range2Low, High: s.Range2High})
+}
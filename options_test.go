@@ -0,0 +1,66 @@
+package dualpng
+
+import (
+	"bytes"
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/Necroforger/dualpng/gamapng"
+)
+
+func TestEncodeColorChunks(t *testing.T) {
+	tests := []struct {
+		chunks []string
+		want   []string
+	}{
+		{nil, []string{"gAMA"}},
+		{[]string{}, nil},
+		{[]string{"sRGB", "gAMA"}, []string{"sRGB", "gAMA"}},
+		{[]string{"gAMA", "cHRM"}, []string{"gAMA", "cHRM"}},
+	}
+	for _, tt := range tests {
+		o := DefaultOptions()
+		o.ColorChunks = tt.chunks
+		var buf bytes.Buffer
+		if err := o.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := gamapng.ReadChunks(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range chunks[1:] {
+			if c.Type == "IDAT" {
+				break
+			}
+			got = append(got, c.Type)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("colour chunks %q wrote %q, want %q", tt.chunks, got, tt.want)
+		}
+	}
+}
+
+func TestValidateZeroGamma(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   func(o *Options)
+		wantOK bool
+	}{
+		{"default chunks", func(o *Options) {}, false},
+		{"gAMA listed", func(o *Options) { o.ColorChunks = []string{"sRGB", "gAMA"} }, false},
+		{"no colour chunks", func(o *Options) { o.ColorChunks = []string{} }, true},
+		{"sRGB only", func(o *Options) { o.ColorChunks = []string{"sRGB"} }, true},
+		{"encoder chunks", func(o *Options) { o.Encoder.ColorChunks = gamapng.SRGBFirst(45455) }, true},
+	}
+	for _, tt := range tests {
+		o := DefaultOptions()
+		o.Gamma = 0
+		tt.opts(&o)
+		if err := o.Validate(); (err == nil) != tt.wantOK {
+			t.Errorf("%s: Validate returned %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package dualpng
+
+import (
+	"encoding/json"
+	"errors"
+	"image/color"
+	"io"
+
+	"github.com/Necroforger/dualpng/gamapng"
+	"github.com/nfnt/resize"
+)
+
+// ParametersKeyword is the keyword of the iTXt chunk that holds the
+// options an image was built with.
+const ParametersKeyword = "dualpng"
+
+// params is the JSON form of Options that is embedded in images. It
+// leaves out what can not be written as JSON, like the Quantizer of the
+// encoder, and the extra chunks, which would otherwise be embedded again
+// every time an image read with ReadOptions is rebuilt.
+type params struct {
+	Width           uint
+	Height          uint
+	Filter          resize.InterpolationFunction
+	Brightness1     float64
+	Brightness2     float64
+	Range1          Levels
+	Range2          Levels
+	Mode            Mode
+	Background1     color.RGBA
+	Background2     color.RGBA
+	Diffusion       Diffusion
+	Mask            [][]float64
+	Gamma           uint32
+	Encoder         encoderParams
+	ColorChunks     []string
+	ICCProfile      *gamapng.ICCProfile
+	EmbedParameters bool
+	Palette         int
+	Depth           int
+	Gray            bool
+}
+
+// encoderParams is the JSON form of gamapng.Encoder.
+type encoderParams struct {
+	CompressionLevel gamapng.CompressionLevel
+	Filter           gamapng.FilterStrategy
+	Workers          int
+	Interlace        bool
+	Grayscale        bool
+	ColorChunks      []gamapng.Chunk
+}
+
+// newParams returns the params of o.
+func newParams(o Options) params {
+	return params{
+		Width:       o.Width,
+		Height:      o.Height,
+		Filter:      o.Filter,
+		Brightness1: o.Brightness1,
+		Brightness2: o.Brightness2,
+		Range1:      o.Range1,
+		Range2:      o.Range2,
+		Mode:        o.Mode,
+		Background1: o.Background1,
+		Background2: o.Background2,
+		Diffusion:   o.Diffusion,
+		Mask:        o.Mask,
+		Gamma:       o.Gamma,
+		Encoder: encoderParams{
+			CompressionLevel: o.Encoder.CompressionLevel,
+			Filter:           o.Encoder.Filter,
+			Workers:          o.Encoder.Workers,
+			Interlace:        o.Encoder.Interlace,
+			Grayscale:        o.Encoder.Grayscale,
+			ColorChunks:      o.Encoder.ColorChunks,
+		},
+		ColorChunks:     o.ColorChunks,
+		ICCProfile:      o.ICCProfile,
+		EmbedParameters: o.EmbedParameters,
+		Palette:         o.Palette,
+		Depth:           o.Depth,
+		Gray:            o.Gray,
+	}
+}
+
+// options returns the Options p holds.
+func (p params) options() Options {
+	return Options{
+		Width:       p.Width,
+		Height:      p.Height,
+		Filter:      p.Filter,
+		Brightness1: p.Brightness1,
+		Brightness2: p.Brightness2,
+		Range1:      p.Range1,
+		Range2:      p.Range2,
+		Mode:        p.Mode,
+		Background1: p.Background1,
+		Background2: p.Background2,
+		Diffusion:   p.Diffusion,
+		Mask:        p.Mask,
+		Gamma:       p.Gamma,
+		Encoder: gamapng.Encoder{
+			CompressionLevel: p.Encoder.CompressionLevel,
+			Filter:           p.Encoder.Filter,
+			Workers:          p.Encoder.Workers,
+			Interlace:        p.Encoder.Interlace,
+			Grayscale:        p.Encoder.Grayscale,
+			ColorChunks:      p.Encoder.ColorChunks,
+		},
+		ColorChunks:     p.ColorChunks,
+		ICCProfile:      p.ICCProfile,
+		EmbedParameters: p.EmbedParameters,
+		Palette:         p.Palette,
+		Depth:           p.Depth,
+		Gray:            p.Gray,
+	}
+}
+
+// MarshalJSON writes the options in the form they are embedded in images.
+func (o Options) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newParams(o))
+}
+
+// UnmarshalJSON reads options written by MarshalJSON.
+func (o *Options) UnmarshalJSON(b []byte) error {
+	var p params
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*o = p.options()
+	return nil
+}
+
+// ParametersChunk returns an iTXt chunk holding the options as JSON.
+// Options.Encode writes it when EmbedParameters is set.
+func (o Options) ParametersChunk() (gamapng.Chunk, error) {
+	b, err := json.Marshal(newParams(o))
+	if err != nil {
+		return gamapng.Chunk{}, err
+	}
+	return gamapng.EncodeTextChunk(gamapng.TextChunk{
+		Type:       "iTXt",
+		Keyword:    ParametersKeyword,
+		Text:       string(b),
+		Compressed: true,
+	})
+}
+
+// ReadOptions reads the options embedded in a dual png by Options.Encode.
+//    r : source of the encoded image
+func ReadOptions(r io.Reader) (Options, error) {
+	_, meta, err := gamapng.DecodeWithMetadata(r)
+	if err != nil {
+		return Options{}, err
+	}
+	for _, t := range meta.Text {
+		if t.Keyword == ParametersKeyword {
+			var p params
+			err := json.Unmarshal([]byte(t.Text), &p)
+			return p.options(), err
+		}
+	}
+	return Options{}, errors.New("Image has no embedded dualpng parameters")
+}
+
+// MarshalText returns the levels in the form accepted by ParseLevels.
+func (l Levels) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText parses levels with ParseLevels.
+func (l *Levels) UnmarshalText(b []byte) error {
+	levels, err := ParseLevels(string(b))
+	if err != nil {
+		return err
+	}
+	*l = levels
+	return nil
+}
@@ -0,0 +1,74 @@
+package dualpng
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Necroforger/dualpng/gamapng"
+)
+
+func TestReadOptions(t *testing.T) {
+	o := DefaultOptions()
+	o.Width = 40
+	o.Mask = [][]float64{{1, 0.5}, {0, 1}}
+	o.Palette = 16
+	o.ColorChunks = []string{"sRGB", "gAMA"}
+	o.EmbedParameters = true
+	o.Encoder.Workers = 2
+	o.Encoder.Interlace = true
+
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	enc := o
+	enc.Encoder.Quantizer = RangeQuantizer{Colors: 16}
+	enc.Encoder.Chunks = []gamapng.Chunk{gamapng.TIMEChunk(time.Unix(0, 0))}
+	if err := enc.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadOptions(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The quantizer and the extra chunks are not embedded.
+	if !reflect.DeepEqual(got, o) {
+		t.Errorf("read %+v, want %+v", got, o)
+	}
+}
+
+func TestParamsCompatible(t *testing.T) {
+	// Parameters as they were embedded before params existed.
+	const old = `{"Width":200,"Height":0,"Filter":5,"Brightness1":1,"Brightness2":1,` +
+		`"Range1":"0-230","Range2":"230-255","Mode":"gamma",` +
+		`"Background1":{"R":0,"G":0,"B":0,"A":0},"Background2":{"R":0,"G":0,"B":0,"A":0},` +
+		`"Diffusion":"","Mask":null,"Gamma":2300,"Encoder":{"CompressionLevel":0,"Filter":0,` +
+		`"Workers":1,"Interlace":false,"Grayscale":false,"ColorChunks":null,"Chunks":null},` +
+		`"ColorChunks":null,"ICCProfile":null,"EmbedParameters":true,"Palette":64,"Depth":8,"Gray":false}`
+	var p params
+	if err := json.Unmarshal([]byte(old), &p); err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultOptions()
+	want.Width = 200
+	want.Brightness1, want.Brightness2 = 1, 1
+	want.Mode = ModeGamma
+	want.Encoder.Workers = 1
+	want.EmbedParameters = true
+	want.Palette = 64
+	want.Depth = 8
+	if got := p.options(); !reflect.DeepEqual(got, want) {
+		t.Errorf("read %+v, want %+v", got, want)
+	}
+
+	b, err := json.Marshal(newParams(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != strings.Replace(old, `,"Chunks":null`, "", 1) {
+		t.Errorf("marshalled %s, want %s", b, old)
+	}
+}
@@ -0,0 +1,251 @@
+package dualpng
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultDensity is the fraction of pixels taken from the first image
+// when a pattern is generated without a density.
+const DefaultDensity = 0.75
+
+// MaxPatternSize is the largest size GeneratePattern accepts, so that a
+// pattern description can not allocate a huge matrix.
+const MaxPatternSize = 256
+
+// PatternFunc returns a matrix of scores for a pattern of the given size.
+// When the pattern is turned into a mask, the cells with the lowest scores
+// are taken from the first image until the density is reached.
+type PatternFunc func(size int) ([][]float64, error)
+
+type pattern struct {
+	gen         PatternFunc
+	defaultSize int
+}
+
+var patterns = map[string]pattern{}
+
+// RegisterPattern adds a named pattern for use with GeneratePattern and ParsePattern.
+//    name        : name of the pattern
+//    defaultSize : size used when a size of zero is requested
+//    gen         : generator of the pattern scores
+func RegisterPattern(name string, defaultSize int, gen PatternFunc) {
+	patterns[name] = pattern{gen, defaultSize}
+}
+
+// Patterns returns the names of the registered patterns in sorted order.
+func Patterns() []string {
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GeneratePattern creates a mask matrix from a registered pattern.
+//    name    : name of the pattern
+//    size    : size of the pattern up to MaxPatternSize, or zero for the pattern's default size
+//    density : fraction of pixels between 0 and 1 to take from the first image
+func GeneratePattern(name string, size int, density float64) ([][]float64, error) {
+	p, ok := patterns[name]
+	if !ok {
+		return nil, errors.New("Unknown pattern: " + name)
+	}
+	if size == 0 {
+		size = p.defaultSize
+	}
+	if size < 0 {
+		return nil, errors.New("Pattern size can not be negative")
+	}
+	if size > MaxPatternSize {
+		return nil, errors.New("Pattern size can be at most " + strconv.Itoa(MaxPatternSize))
+	}
+	if density < 0 || density > 1 {
+		return nil, errors.New("Pattern density must be between 0 and 1")
+	}
+	scores, err := p.gen(size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rank the cells by score, ties are broken by position.
+	type cell struct {
+		i, j  int
+		score float64
+	}
+	var cells []cell
+	for i, row := range scores {
+		for j, score := range row {
+			cells = append(cells, cell{i, j, score})
+		}
+	}
+	sort.SliceStable(cells, func(a, b int) bool {
+		return cells[a].score < cells[b].score
+	})
+
+	m := make([][]float64, len(scores))
+	for i := range m {
+		m[i] = make([]float64, len(scores[i]))
+	}
+	n := int(math.Round(density * float64(len(cells))))
+	for _, c := range cells[:n] {
+		m[c.i][c.j] = 1
+	}
+	return m, nil
+}
+
+// ParsePattern creates a mask matrix from a pattern description of the form
+// "name[:size[:density]]", for example "bayer:8:0.75".
+// A size of zero or an omitted size uses the pattern's default size.
+// An omitted density uses DefaultDensity.
+func ParsePattern(txt string) ([][]float64, error) {
+	var (
+		fields  = strings.Split(txt, ":")
+		size    int
+		density = DefaultDensity
+		err     error
+	)
+	if len(fields) > 3 {
+		return nil, errors.New("Invalid pattern: " + txt)
+	}
+	if len(fields) > 1 {
+		size, err = strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, errors.New("Invalid pattern size: " + fields[1])
+		}
+	}
+	if len(fields) > 2 {
+		density, err = strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, errors.New("Invalid pattern density: " + fields[2])
+		}
+	}
+	return GeneratePattern(fields[0], size, density)
+}
+
+// newMatrix creates a rows by cols matrix with every cell set to f(i, j).
+func newMatrix(rows, cols int, f func(i, j int) float64) [][]float64 {
+	m := make([][]float64, rows)
+	for i := range m {
+		m[i] = make([]float64, cols)
+		for j := range m[i] {
+			m[i][j] = f(i, j)
+		}
+	}
+	return m
+}
+
+// checkerboard alternates squares of size by size pixels.
+func checkerboard(size int) ([][]float64, error) {
+	return newMatrix(2*size, 2*size, func(i, j int) float64 {
+		return float64((i/size + j/size) % 2)
+	}), nil
+}
+
+// thread weaves two diagonals crossing every size pixels.
+func thread(size int) ([][]float64, error) {
+	return newMatrix(size, size, func(i, j int) float64 {
+		return math.Min(float64((i+j)%size), float64((i-j+size)%size))
+	}), nil
+}
+
+// horizontal repeats horizontal lines every size pixels.
+func horizontal(size int) ([][]float64, error) {
+	return newMatrix(size, 1, func(i, j int) float64 {
+		return float64(i)
+	}), nil
+}
+
+// vertical repeats vertical lines every size pixels.
+func vertical(size int) ([][]float64, error) {
+	return newMatrix(1, size, func(i, j int) float64 {
+		return float64(j)
+	}), nil
+}
+
+// diagonal repeats diagonal lines every size pixels.
+func diagonal(size int) ([][]float64, error) {
+	return newMatrix(size, size, func(i, j int) float64 {
+		return float64((i + j) % size)
+	}), nil
+}
+
+// bayer creates the ordered dither matrix of size by size pixels.
+// The size must be a power of two.
+func bayer(size int) ([][]float64, error) {
+	if size&(size-1) != 0 {
+		return nil, errors.New("Bayer pattern size must be a power of two")
+	}
+	return newMatrix(size, size, func(i, j int) float64 {
+		// Interleave the bits of i^j and i in reverse order.
+		v := 0
+		for bit := 1; bit < size; bit <<= 1 {
+			v <<= 2
+			if (i^j)&bit != 0 {
+				v |= 2
+			}
+			if i&bit != 0 {
+				v |= 1
+			}
+		}
+		return float64(v)
+	}), nil
+}
+
+// maxBlueNoiseSize limits the cost of generating blue noise, which grows
+// with the fourth power of the size.
+const maxBlueNoiseSize = 64
+
+// blueNoise creates a size by size blue noise threshold matrix by repeatedly
+// filling the largest void, the cell furthest away from the cells filled so far.
+// The matrix wraps around so that it tiles without seams.
+func blueNoise(size int) ([][]float64, error) {
+	if size > maxBlueNoiseSize {
+		return nil, errors.New("Blue noise pattern size can be at most " + strconv.Itoa(maxBlueNoiseSize))
+	}
+
+	// Gaussian energy of a filled cell at every wrapped offset.
+	const sigma = 1.5
+	kernel := newMatrix(size, size, func(i, j int) float64 {
+		di := math.Min(float64(i), float64(size-i))
+		dj := math.Min(float64(j), float64(size-j))
+		return math.Exp(-(di*di + dj*dj) / (2 * sigma * sigma))
+	})
+
+	var (
+		energy = newMatrix(size, size, func(i, j int) float64 { return 0 })
+		filled = make([]bool, size*size)
+		rank   = newMatrix(size, size, func(i, j int) float64 { return 0 })
+	)
+	for n := 0; n < size*size; n++ {
+		best := -1
+		for k, done := range filled {
+			if !done && (best < 0 || energy[k/size][k%size] < energy[best/size][best%size]) {
+				best = k
+			}
+		}
+		filled[best] = true
+		bi, bj := best/size, best%size
+		rank[bi][bj] = float64(n)
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				energy[i][j] += kernel[(i-bi+size)%size][(j-bj+size)%size]
+			}
+		}
+	}
+	return rank, nil
+}
+
+func init() {
+	RegisterPattern("checkerboard", 1, checkerboard)
+	RegisterPattern("thread", 6, thread)
+	RegisterPattern("horizontal", 2, horizontal)
+	RegisterPattern("vertical", 2, vertical)
+	RegisterPattern("diagonal", 4, diagonal)
+	RegisterPattern("bayer", 4, bayer)
+	RegisterPattern("bluenoise", 16, blueNoise)
+}
@@ -0,0 +1,116 @@
+package dualpng
+
+import (
+	"image"
+	"runtime"
+	"sync"
+)
+
+// parallelRows splits the rows between y0 and y1 into one band per
+// processor and calls fn for each band concurrently.
+func parallelRows(y0, y1 int, fn func(y0, y1 int)) {
+	n := runtime.GOMAXPROCS(0)
+	if rows := y1 - y0; rows < n {
+		n = rows
+	}
+	if n <= 1 {
+		fn(y0, y1)
+		return
+	}
+
+	var wg sync.WaitGroup
+	band := (y1 - y0 + n - 1) / n
+	for start := y0; start < y1; start += band {
+		end := start + band
+		if end > y1 {
+			end = y1
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// readRow reads the pixels of img between x0 and x1 on row y into dst
+// as the alpha premultiplied 16 bit values returned by color.Color.RGBA.
+// Pixels outside of the image's bounds are transparent black.
+// dst must hold 4*(x1-x0) values.
+func readRow(img image.Image, y, x0, x1 int, dst []uint16) {
+	b := img.Bounds()
+	lo, hi := x0, x1
+	if lo < b.Min.X {
+		lo = b.Min.X
+	}
+	if hi > b.Max.X {
+		hi = b.Max.X
+	}
+	if y < b.Min.Y || y >= b.Max.Y || lo >= hi {
+		for i := range dst {
+			dst[i] = 0
+		}
+		return
+	}
+	for i := 0; i < 4*(lo-x0); i++ {
+		dst[i] = 0
+	}
+	for i := 4 * (hi - x0); i < len(dst); i++ {
+		dst[i] = 0
+	}
+	d := dst[4*(lo-x0) : 4*(hi-x0)]
+
+	switch m := img.(type) {
+	case *image.RGBA:
+		pix := m.Pix[m.PixOffset(lo, y) : m.PixOffset(lo, y)+4*(hi-lo)]
+		for i, v := range pix {
+			d[i] = uint16(v) * 0x101
+		}
+	case *image.NRGBA:
+		pix := m.Pix[m.PixOffset(lo, y) : m.PixOffset(lo, y)+4*(hi-lo)]
+		for i := 0; i < len(pix); i += 4 {
+			// Premultiply the same way color.NRGBA.RGBA does.
+			a := uint32(pix[i+3]) * 0x101
+			d[i+0] = uint16(uint32(pix[i+0]) * 0x101 * a / 0xffff)
+			d[i+1] = uint16(uint32(pix[i+1]) * 0x101 * a / 0xffff)
+			d[i+2] = uint16(uint32(pix[i+2]) * 0x101 * a / 0xffff)
+			d[i+3] = uint16(a)
+		}
+	case *image.RGBA64:
+		pix := m.Pix[m.PixOffset(lo, y) : m.PixOffset(lo, y)+8*(hi-lo)]
+		for i := range d {
+			d[i] = uint16(pix[2*i])<<8 | uint16(pix[2*i+1])
+		}
+	case *image.YCbCr:
+		for x, i := lo, 0; x < hi; x, i = x+1, i+4 {
+			r, g, b, a := m.YCbCrAt(x, y).RGBA()
+			d[i+0], d[i+1], d[i+2], d[i+3] = uint16(r), uint16(g), uint16(b), uint16(a)
+		}
+	default:
+		for x, i := lo, 0; x < hi; x, i = x+1, i+4 {
+			r, g, b, a := img.At(x, y).RGBA()
+			d[i+0], d[i+1], d[i+2], d[i+3] = uint16(r), uint16(g), uint16(b), uint16(a)
+		}
+	}
+}
+
+// writeRow64 writes the alpha premultiplied 16 bit values in row to row y
+// of img, starting at the left edge of img.
+func writeRow64(img *image.RGBA64, y int, row []uint16) {
+	pix := img.Pix[img.PixOffset(img.Rect.Min.X, y):]
+	for i, v := range row {
+		pix[2*i+0] = uint8(v >> 8)
+		pix[2*i+1] = uint8(v)
+	}
+}
+
+// grayRow replaces the colour of every pixel in a row read by readRow with
+// its luminance, using the same weights as color.Gray16Model.
+func grayRow(row []uint16) {
+	for i := 0; i < len(row); i += 4 {
+		r, g, b := uint32(row[i+0]), uint32(row[i+1]), uint32(row[i+2])
+		y := uint16((19595*r + 38470*g + 7471*b + 1<<15) >> 16)
+		row[i+0], row[i+1], row[i+2] = y, y, y
+	}
+}
@@ -0,0 +1,217 @@
+package dualpng
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// testImages returns w by h images of every type readRow has a fast path
+// for, and one that goes through At, filled with the same random pixels.
+func testImages(w, h int) map[string]image.Image {
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, w, h)
+	var (
+		rgba  = image.NewRGBA(r)
+		nrgba = image.NewNRGBA(r)
+		ycc   = image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+		gray  = image.NewGray16(r)
+	)
+	rnd.Read(nrgba.Pix)
+	for i := 3; i < len(nrgba.Pix); i += 4 {
+		// Make fully transparent and opaque pixels common.
+		switch rnd.Intn(4) {
+		case 0:
+			nrgba.Pix[i] = 0
+		case 1:
+			nrgba.Pix[i] = 0xff
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			rgba.Set(x, y, nrgba.At(x, y))
+		}
+	}
+	rnd.Read(ycc.Y)
+	rnd.Read(ycc.Cb)
+	rnd.Read(ycc.Cr)
+	rnd.Read(gray.Pix)
+	return map[string]image.Image{"RGBA": rgba, "NRGBA": nrgba, "YCbCr": ycc, "Gray16": gray}
+}
+
+// levelAt is LevelChannels as it was written with At and Set.
+func levelAt(img image.Image, levels Levels) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	lr, lg, lb := levels.R.lut(), levels.G.lut(), levels.B.lut()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			i := out.PixOffset(x, y)
+			out.Pix[i+0] = lr[r>>8]
+			out.Pix[i+1] = lg[g>>8]
+			out.Pix[i+2] = lb[b>>8]
+			out.Pix[i+3] = uint8(a >> 8)
+		}
+	}
+	return out
+}
+
+// scaleAt is ScaleBrightness as it was written with At and Set.
+func scaleAt(img image.Image, scale float64) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	b := img.Bounds()
+	brighten := func(n uint32) uint8 {
+		b := uint32(float64(n)*scale) >> 8
+		if b > 255 {
+			b = 255
+		}
+		return uint8(b)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			out.Set(x, y, color.RGBA{brighten(r), brighten(g), brighten(b), uint8(a >> 8)})
+		}
+	}
+	return out
+}
+
+// mergeAt is MergeImages as it was written with At, except that pixels
+// outside an image are transparent. At returned opaque black there for
+// some image types and, for YCbCr, green.
+func mergeAt(img1, img2 image.Image, maskmatrix [][]float64) *image.RGBA {
+	b1, b2 := img1.Bounds(), img2.Bounds()
+	w, h := b1.Dx(), b1.Dy()
+	if b2.Dx() > w {
+		w = b2.Dx()
+	}
+	if b2.Dy() > h {
+		h = b2.Dy()
+	}
+	combined := image.NewRGBA(image.Rect(0, 0, w, h))
+	if maskmatrix == nil {
+		maskmatrix = DefaultMask
+	}
+	blend := func(c1, c2, w uint32) uint8 {
+		return uint8((c1*w + c2*(255-w)) / 255 >> 8)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := combined.PixOffset(x, y)
+			row := maskmatrix[y%len(maskmatrix)]
+			m := uint32(clamp(row[x%len(row)], 0, 1)*255 + 0.5)
+			r1, g1, b1, a1 := at(img1, x, y).RGBA()
+			r2, g2, b2, a2 := at(img2, x, y).RGBA()
+			combined.Pix[i+0] = blend(r1, r2, m)
+			combined.Pix[i+1] = blend(g1, g2, m)
+			combined.Pix[i+2] = blend(b1, b2, m)
+			combined.Pix[i+3] = blend(a1, a2, m)
+		}
+	}
+	return combined
+}
+
+// at returns the colour of img at (x, y), which is transparent outside of
+// the image's bounds.
+func at(img image.Image, x, y int) color.Color {
+	if !image.Pt(x, y).In(img.Bounds()) {
+		return color.Transparent
+	}
+	return img.At(x, y)
+}
+
+// comparePix reports the first pixel where got and want differ.
+func comparePix(t *testing.T, name string, got, want *image.RGBA) {
+	t.Helper()
+	if got.Rect != want.Rect {
+		t.Errorf("%s: bounds %v, want %v", name, got.Rect, want.Rect)
+		return
+	}
+	for i := range got.Pix {
+		if got.Pix[i] != want.Pix[i] {
+			p := i / 4
+			x, y := p%want.Rect.Dx(), p/want.Rect.Dx()
+			t.Errorf("%s: pixel (%d, %d) is %v, want %v", name, x, y,
+				got.Pix[4*p:4*p+4], want.Pix[4*p:4*p+4])
+			return
+		}
+	}
+}
+
+func TestFastPathsMatchAt(t *testing.T) {
+	levels, err := ParseLevels("20-230@0.5,0-200,40-255@2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgs := testImages(67, 41)
+	small := testImages(50, 60)
+	mask := [][]float64{{1, 0.5, 0}, {0.25, 1, 0.75}}
+	for name, img := range imgs {
+		comparePix(t, name+" LevelChannels", LevelChannels(img, levels), levelAt(img, levels))
+		for _, scale := range []float64{0.5, 1, 1.7} {
+			comparePix(t, fmt.Sprint(name, " ScaleBrightness ", scale), ScaleBrightness(img, scale), scaleAt(img, scale))
+		}
+		for name2, img2 := range small {
+			comparePix(t, name+"+"+name2+" MergeImages", MergeImages(img, img2, mask), mergeAt(img, img2, mask))
+			comparePix(t, name2+"+"+name+" MergeImages", MergeImages(img2, img, nil), mergeAt(img2, img, nil))
+		}
+	}
+}
+
+// benchW and benchH are the size of a 4K frame.
+const benchW, benchH = 3840, 2160
+
+func BenchmarkLevelImage(b *testing.B) {
+	imgs := testImages(benchW, benchH)
+	for _, name := range []string{"RGBA", "NRGBA", "YCbCr"} {
+		img := imgs[name]
+		levels := UniformLevels(Range{Low: 0, High: 230})
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				LevelImage(img, 0, 230)
+			}
+		})
+		b.Run(name+"/At", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				levelAt(img, levels)
+			}
+		})
+	}
+}
+
+func BenchmarkScaleBrightness(b *testing.B) {
+	imgs := testImages(benchW, benchH)
+	for _, name := range []string{"RGBA", "NRGBA", "YCbCr"} {
+		img := imgs[name]
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ScaleBrightness(img, 1.2)
+			}
+		})
+		b.Run(name+"/At", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scaleAt(img, 1.2)
+			}
+		})
+	}
+}
+
+func BenchmarkMergeImages(b *testing.B) {
+	imgs := testImages(benchW, benchH)
+	for _, name := range []string{"RGBA", "NRGBA", "YCbCr"} {
+		img1, img2 := imgs[name], imgs["RGBA"]
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				MergeImages(img1, img2, nil)
+			}
+		})
+		b.Run(name+"/At", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				mergeAt(img1, img2, nil)
+			}
+		})
+	}
+}
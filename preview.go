@@ -0,0 +1,67 @@
+package dualpng
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+
+	"github.com/Necroforger/dualpng/gamapng"
+)
+
+// DefaultDisplayGamma is the display exponent assumed for a typical monitor.
+const DefaultDisplayGamma = 2.2
+
+// RenderGamma simulates a viewer that honours the gAMA chunk by
+// applying the decoding exponent 1 / (gAMA * displayGamma) to every pixel.
+// If gAMA is zero the image is returned unchanged.
+//    img          : source image
+//    gAMA         : gAMA value multiplied by 100,000
+//    displayGamma : exponent of the simulated display, usually 2.2
+func RenderGamma(img image.Image, gAMA uint32, displayGamma float64) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	if gAMA == 0 || displayGamma <= 0 {
+		return out
+	}
+
+	exponent := 1 / (float64(gAMA) / 100000 * displayGamma)
+	var lut [256]uint8
+	for i := range lut {
+		lut[i] = uint8(math.Pow(float64(i)/255, exponent)*255 + 0.5)
+	}
+	for i := 0; i < len(out.Pix); i += 4 {
+		out.Pix[i+0] = lut[out.Pix[i+0]]
+		out.Pix[i+1] = lut[out.Pix[i+1]]
+		out.Pix[i+2] = lut[out.Pix[i+2]]
+	}
+	return out
+}
+
+// RenderFlat simulates a viewer that ignores the gAMA chunk, such as the
+// image previews in Discord or Twitter, by compositing the raw pixels
+// over the background colour bg.
+//    img : source image
+//    bg  : background colour the viewer displays the image on
+func RenderFlat(img image.Image, bg color.Color) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, image.NewUniform(bg), image.ZP, draw.Src)
+	draw.Draw(out, b, img, b.Min, draw.Over)
+	return out
+}
+
+// Preview decodes a gamapng encoded image from r and renders
+// how it appears in viewers that do and do not apply gamma correction.
+//    r            : source of the encoded png
+//    displayGamma : exponent of the simulated display, usually 2.2
+//    bg           : background colour for the viewer that ignores gamma
+func Preview(r io.Reader, displayGamma float64, bg color.Color) (gamma *image.NRGBA, flat *image.RGBA, err error) {
+	img, meta, err := gamapng.DecodeWithMetadata(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return RenderGamma(img, meta.Gamma, displayGamma), RenderFlat(img, bg), nil
+}
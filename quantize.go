@@ -0,0 +1,292 @@
+package dualpng
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// RangeQuantizer reduces an image created by Build to a palette while
+// keeping the colours of the two ranges apart, so that the gamma trick
+// still works after quantization. Every colour is grouped with the range
+// it is closest to, each group gets its own share of the palette, and
+// pixels are only mapped to palette colours of their own group.
+// It implements both draw.Quantizer and draw.Drawer.
+type RangeQuantizer struct {
+	Range1, Range2 Levels
+
+	// Colors is the maximum number of colours in the palette.
+	// Zero uses the capacity of the palette passed to Quantize.
+	Colors int
+}
+
+// colorCount is a colour of the image and the number of pixels with it.
+type colorCount struct {
+	c [4]uint8
+	n int
+}
+
+// Quantize appends a palette for m to p. It implements draw.Quantizer.
+func (q RangeQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	colors := cap(p) - len(p)
+	if q.Colors > 0 && q.Colors < colors {
+		colors = q.Colors
+	}
+	if colors <= 0 {
+		return p
+	}
+
+	// Count the colours of each group.
+	var hist [2]map[uint64]*colorCount
+	for g := range hist {
+		hist[g] = map[uint64]*colorCount{}
+	}
+	b := m.Bounds()
+	row := make([]uint16, 4*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		readRow(m, y, b.Min.X, b.Max.X, row)
+		for i := 0; i < len(row); i += 4 {
+			c := toNRGBA(row[i:])
+			k := uint64(c.R)<<24 | uint64(c.G)<<16 | uint64(c.B)<<8 | uint64(c.A)
+			g := q.group(c)
+			if cc, ok := hist[g][k]; ok {
+				cc.n++
+			} else {
+				hist[g][k] = &colorCount{[4]uint8{c.R, c.G, c.B, c.A}, 1}
+			}
+		}
+	}
+
+	var (
+		counts [2][]colorCount
+		pixels [2]int
+	)
+	for g := range hist {
+		for _, cc := range hist[g] {
+			counts[g] = append(counts[g], *cc)
+			pixels[g] += cc.n
+		}
+		// Map iteration order is random, sort for a stable palette.
+		sort.Slice(counts[g], func(i, j int) bool {
+			a, b := counts[g][i].c, counts[g][j].c
+			return uint32(a[0])<<24|uint32(a[1])<<16|uint32(a[2])<<8|uint32(a[3]) <
+				uint32(b[0])<<24|uint32(b[1])<<16|uint32(b[2])<<8|uint32(b[3])
+		})
+	}
+
+	// Share the palette between the groups by their number of pixels.
+	var share [2]int
+	share[0], share[1] = len(counts[0]), len(counts[1])
+	if share[0]+share[1] > colors {
+		share[0] = int(float64(colors)*float64(pixels[0])/float64(pixels[0]+pixels[1]) + 0.5)
+		if len(counts[0]) > 0 && share[0] < 1 {
+			share[0] = 1
+		}
+		if len(counts[1]) > 0 && share[0] > colors-1 {
+			share[0] = colors - 1
+		}
+		if share[0] > len(counts[0]) {
+			share[0] = len(counts[0])
+		}
+		share[1] = colors - share[0]
+		if share[1] > len(counts[1]) {
+			share[1] = len(counts[1])
+			share[0] = colors - share[1]
+		}
+	}
+
+	for g := range counts {
+		g := g
+		in := func(c color.NRGBA) bool { return q.group(c) == g }
+		for _, c := range medianCut(counts[g], share[g], in) {
+			p = append(p, c)
+		}
+	}
+	return p
+}
+
+// Draw maps the pixels of src to the closest palette colour of the same
+// group when dst is an *image.Paletted. Pixels of a group the palette has
+// no colour for, as with a palette of one colour, use the other group.
+// It implements draw.Drawer.
+func (q RangeQuantizer) Draw(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) {
+	pm, ok := dst.(*image.Paletted)
+	if !ok {
+		draw.Draw(dst, r, src, sp, draw.Src)
+		return
+	}
+	r = r.Intersect(dst.Bounds())
+
+	// Indices of the palette colours in each group.
+	var entries [2][]int
+	for i, c := range pm.Palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		entries[q.group(n)] = append(entries[q.group(n)], i)
+	}
+	for g := range entries {
+		if len(entries[g]) == 0 {
+			entries[g] = entries[1-g]
+		}
+	}
+
+	cache := map[uint64]uint8{}
+	row := make([]uint16, 4*r.Dx())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sy := y - r.Min.Y + sp.Y
+		readRow(src, sy, sp.X, sp.X+r.Dx(), row)
+		for i, x := 0, r.Min.X; x < r.Max.X; i, x = i+4, x+1 {
+			k := uint64(row[i])<<48 | uint64(row[i+1])<<32 | uint64(row[i+2])<<16 | uint64(row[i+3])
+			idx, ok := cache[k]
+			if !ok {
+				c := toNRGBA(row[i:])
+				idx = nearest(pm.Palette, entries[q.group(c)], c)
+				cache[k] = idx
+			}
+			pm.Pix[pm.PixOffset(x, y)] = idx
+		}
+	}
+}
+
+// group returns 0 if c is closer to the first range and 1 if it is closer
+// to the second. Colours on the border of both belong to the first range.
+func (q RangeQuantizer) group(c color.NRGBA) int {
+	if q.Range1.distance(c) <= q.Range2.distance(c) {
+		return 0
+	}
+	return 1
+}
+
+// distance returns how far the colour channels of c are outside of the levels.
+func (l Levels) distance(c color.NRGBA) int {
+	d := 0
+	values := [3]uint8{c.R, c.G, c.B}
+	for i, r := range [3]Range{l.R, l.G, l.B} {
+		v, low, high := values[i], r.Low, r.High
+		if low > high {
+			low, high = high, low
+		}
+		if v < low {
+			d += int(low - v)
+		} else if v > high {
+			d += int(v - high)
+		}
+	}
+	return d
+}
+
+// toNRGBA converts four alpha premultiplied 16 bit values read by readRow
+// to a non alpha premultiplied colour.
+func toNRGBA(v []uint16) color.NRGBA {
+	return color.NRGBAModel.Convert(color.RGBA64{v[0], v[1], v[2], v[3]}).(color.NRGBA)
+}
+
+// nearest returns the index of the palette colour among entries closest to c.
+func nearest(p color.Palette, entries []int, c color.NRGBA) uint8 {
+	best, bestDist := 0, -1
+	for _, i := range entries {
+		n := color.NRGBAModel.Convert(p[i]).(color.NRGBA)
+		dr, dg, db, da := int(n.R)-int(c.R), int(n.G)-int(c.G), int(n.B)-int(c.B), int(n.A)-int(c.A)
+		d := dr*dr + dg*dg + db*db + da*da
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return uint8(best)
+}
+
+// medianCut reduces colours to at most n colours by repeatedly splitting the
+// box of colours with the widest channel at its median, then averaging the
+// colours in each box weighted by their number of pixels. An average for
+// which in returns false is replaced by the most common colour of its box,
+// so that Draw finds every palette colour in the group it was made for.
+func medianCut(colors []colorCount, n int, in func(color.NRGBA) bool) []color.NRGBA {
+	if n <= 0 || len(colors) == 0 {
+		return nil
+	}
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		// Split the box with the widest channel, weighted by its pixels.
+		best, bestScore, bestChannel := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, width := widestChannel(box)
+			pixels := 0
+			for _, cc := range box {
+				pixels += cc.n
+			}
+			if score := width * pixels; best < 0 || score > bestScore {
+				best, bestScore, bestChannel = i, score, channel
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool {
+			return box[i].c[bestChannel] < box[j].c[bestChannel]
+		})
+		total := 0
+		for _, cc := range box {
+			total += cc.n
+		}
+		split, sum := 1, 0
+		for i, cc := range box[:len(box)-1] {
+			sum += cc.n
+			split = i + 1
+			if 2*sum >= total {
+				break
+			}
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	out := make([]color.NRGBA, len(boxes))
+	for i, box := range boxes {
+		var sum [4]int
+		pixels, common := 0, box[0]
+		for _, cc := range box {
+			for c := range sum {
+				sum[c] += int(cc.c[c]) * cc.n
+			}
+			pixels += cc.n
+			if cc.n > common.n {
+				common = cc
+			}
+		}
+		out[i] = color.NRGBA{
+			uint8((sum[0] + pixels/2) / pixels),
+			uint8((sum[1] + pixels/2) / pixels),
+			uint8((sum[2] + pixels/2) / pixels),
+			uint8((sum[3] + pixels/2) / pixels),
+		}
+		if !in(out[i]) {
+			out[i] = color.NRGBA{common.c[0], common.c[1], common.c[2], common.c[3]}
+		}
+	}
+	return out
+}
+
+// widestChannel returns the channel whose values are furthest apart in box
+// and the distance between them.
+func widestChannel(box []colorCount) (channel, width int) {
+	for c := 0; c < 4; c++ {
+		low, high := box[0].c[c], box[0].c[c]
+		for _, cc := range box {
+			if cc.c[c] < low {
+				low = cc.c[c]
+			}
+			if cc.c[c] > high {
+				high = cc.c[c]
+			}
+		}
+		if int(high-low) > width {
+			channel, width = c, int(high-low)
+		}
+	}
+	return channel, width
+}
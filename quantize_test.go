@@ -0,0 +1,57 @@
+package dualpng
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// checkGroups quantizes src with q and fails if a pixel is mapped to a
+// colour of the other group.
+func checkGroups(t *testing.T, name string, q RangeQuantizer, src image.Image) {
+	t.Helper()
+	b := src.Bounds()
+	dst := image.NewPaletted(b, q.Quantize(make(color.Palette, 0, 256), src))
+	q.Draw(dst, b, src, b.Min)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := q.group(nrgbaAt(src, x, y))
+			got := q.group(nrgbaAt(dst, x, y))
+			if got != want {
+				t.Fatalf("%s: pixel (%d, %d) %v of group %d became %v of group %d",
+					name, x, y, src.At(x, y), want, dst.At(x, y), got)
+			}
+		}
+	}
+}
+
+// nrgbaAt returns the colour of m at (x, y) the way RangeQuantizer sees it,
+// where fully transparent pixels are transparent black.
+func nrgbaAt(m image.Image, x, y int) color.NRGBA {
+	r, g, b, a := m.At(x, y).RGBA()
+	return toNRGBA([]uint16{uint16(r), uint16(g), uint16(b), uint16(a)})
+}
+
+func TestRangeQuantizerKeepsGroups(t *testing.T) {
+	q := RangeQuantizer{
+		Range1: Levels{R: Range{Low: 0, High: 255}, G: Range{Low: 0, High: 100}, B: Range{Low: 0, High: 100}},
+		Range2: Levels{R: Range{Low: 120, High: 135}, G: Range{Low: 150, High: 255}, B: Range{Low: 150, High: 255}},
+	}
+
+	// The first two colours are closer to the first range, but their
+	// average (128, 228, 94) is closer to the second.
+	m := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	m.Set(0, 0, color.NRGBA{0, 200, 128, 255})
+	m.Set(1, 0, color.NRGBA{255, 255, 60, 255})
+	m.Set(2, 0, color.NRGBA{128, 255, 255, 255})
+	q.Colors = 2
+	checkGroups(t, "average", q, m)
+
+	for name, src := range testImages(64, 48) {
+		for _, colors := range []int{2, 16, 256} {
+			q.Colors = colors
+			checkGroups(t, fmt.Sprint(name, " ", colors, " colours"), q, src)
+		}
+	}
+}
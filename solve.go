@@ -0,0 +1,157 @@
+package dualpng
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Solution holds the parameters chosen by Solve.
+type Solution struct {
+	Gamma      uint32 // gAMA value multiplied by 100,000
+	Range1Low  uint8
+	Range1High uint8
+	Range2Low  uint8
+	Range2High uint8
+
+	// GammaScore is how well the second image shows through in a viewer
+	// that applies gamma, and FlatScore is how well the first image shows
+	// through in a viewer that does not. Scores are at most 1, higher is better.
+	GammaScore float64
+	FlatScore  float64
+}
+
+// solveSamples is the number of sample points along each axis.
+const solveSamples = 64
+
+// solveCoverage is the fraction of pixels the default mask takes from the first image.
+const solveCoverage = 0.75
+
+// Solve searches for the gAMA value and colour ranges that make img1
+// visible in viewers that ignore gamma and img2 visible in viewers that
+// apply it, assuming the default mask is used when merging.
+//    img1         : image shown without gamma correction
+//    img2         : image shown with gamma correction
+//    displayGamma : exponent of the display, usually 2.2
+//    bg           : background colour the images are shown on
+func Solve(img1, img2 image.Image, displayGamma float64, bg color.Color) Solution {
+	if displayGamma <= 0 {
+		displayGamma = DefaultDisplayGamma
+	}
+	bgr, bgg, bgb, _ := bg.RGBA()
+	bgLum := luminance(bgr, bgg, bgb) / 0xffff
+
+	l1, a1 := sampleLuminance(img1)
+	l2, a2 := sampleLuminance(img2)
+	alpha := make([]float64, len(l1))
+	for i := range alpha {
+		alpha[i] = solveCoverage*a1[i] + (1-solveCoverage)*a2[i]
+	}
+
+	var (
+		best      Solution
+		bestScore = math.Inf(-1)
+		flat      = make([]float64, len(l1))
+		gamma     = make([]float64, len(l1))
+		lut       [256]float64
+	)
+	for split := 128; split <= 252; split += 2 {
+		s := float64(split) / 255
+		for g := 500.0; g <= 20000; g *= 1.1 {
+			exponent := 1 / (g / 100000 * displayGamma)
+			for i := range lut {
+				lut[i] = math.Pow(float64(i)/255, exponent)
+			}
+			for i := range l1 {
+				// Quantize to 8 bits like LevelImage does.
+				v1 := math.Floor(l1[i]*s*255) / 255
+				v2 := math.Floor((s+l2[i]*(1-s))*255) / 255
+				flat[i] = solveCoverage*v1 + (1-solveCoverage)*v2
+				gamma[i] = solveCoverage*lut[int(v1*255+0.5)] + (1-solveCoverage)*lut[int(v2*255+0.5)]
+
+				flat[i] = alpha[i]*flat[i] + (1-alpha[i])*bgLum
+				gamma[i] = alpha[i]*gamma[i] + (1-alpha[i])*bgLum
+			}
+			flatScore := visibility(flat, l1, l2)
+			gammaScore := visibility(gamma, l2, l1)
+			if score := math.Min(flatScore, gammaScore); score > bestScore {
+				bestScore = score
+				best = Solution{
+					Gamma:      uint32(g),
+					Range1Low:  0,
+					Range1High: uint8(split),
+					Range2Low:  uint8(split),
+					Range2High: 255,
+					GammaScore: gammaScore,
+					FlatScore:  flatScore,
+				}
+			}
+		}
+	}
+	return best
+}
+
+// sampleLuminance samples the luminance and alpha of img on an evenly spaced
+// grid of solveSamples by solveSamples points. Values are between 0 and 1.
+func sampleLuminance(img image.Image) (lum, alpha []float64) {
+	b := img.Bounds()
+	lum = make([]float64, 0, solveSamples*solveSamples)
+	alpha = make([]float64, 0, solveSamples*solveSamples)
+	for i := 0; i < solveSamples; i++ {
+		y := b.Min.Y + (2*i+1)*b.Dy()/(2*solveSamples)
+		for j := 0; j < solveSamples; j++ {
+			x := b.Min.X + (2*j+1)*b.Dx()/(2*solveSamples)
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a == 0 {
+				lum = append(lum, 0)
+			} else {
+				// Undo the alpha premultiplication.
+				lum = append(lum, luminance(r, g, bl)/float64(a))
+			}
+			alpha = append(alpha, float64(a)/0xffff)
+		}
+	}
+	return lum, alpha
+}
+
+// luminance returns the Rec. 601 luma of 16 bit colour components.
+func luminance(r, g, b uint32) float64 {
+	return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+}
+
+// visibility scores how well the rendered values show target and hide other.
+// It is the correlation with target minus the correlation with other,
+// scaled down when the render has too little contrast to make out.
+func visibility(render, target, other []float64) float64 {
+	mean, std := meanStd(render)
+	if std == 0 {
+		return -1
+	}
+	contrast := math.Min(1, 4*std)
+	return (correlation(render, mean, std, target) - math.Abs(correlation(render, mean, std, other))) * contrast
+}
+
+func meanStd(v []float64) (mean, std float64) {
+	for _, x := range v {
+		mean += x
+	}
+	mean /= float64(len(v))
+	for _, x := range v {
+		std += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(v)))
+}
+
+// correlation returns the Pearson correlation of a and b, given the mean and
+// standard deviation of a.
+func correlation(a []float64, meanA, stdA float64, b []float64) float64 {
+	meanB, stdB := meanStd(b)
+	if stdB == 0 {
+		return 0
+	}
+	var cov float64
+	for i := range a {
+		cov += (a[i] - meanA) * (b[i] - meanB)
+	}
+	return cov / float64(len(a)) / (stdA * stdB)
+}
@@ -0,0 +1,176 @@
+package dualpng
+
+import (
+	"errors"
+	"image"
+	"image/color"
+
+	"github.com/nfnt/resize"
+)
+
+// MergeSource merges two images the same way Build does in ModeGamma, but
+// computes each row when it is read instead of holding the merged image,
+// the brightened and leveled copies of the images or the mask in memory.
+// Options.Encode reads it a row at a time, so only the two resized images
+// are kept, which makes poster sized images possible with little memory.
+// It implements gamapng.RowImage and is safe for concurrent use.
+type MergeSource struct {
+	img1, img2     image.Image
+	scale1, scale2 float64
+	lut1, lut2     [3]*[256]uint8
+	gray           bool
+	mask           tiledMask
+	rect           image.Rectangle
+}
+
+// NewMergeSource resizes img1 and img2 and returns a MergeSource that
+// merges them according to the options. Only ModeGamma at 8 bits without
+// a palette is supported. The images must not be modified while the
+// source is in use.
+//    img1 : image visible when gamma correction is not applied
+//    img2 : image visible when gamma correction is applied
+//    o    : options
+func NewMergeSource(img1, img2 image.Image, o Options) (*MergeSource, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if o.Mode != "" && o.Mode != ModeGamma {
+		return nil, errors.New("Streaming only supports gamma mode")
+	}
+	if o.Depth == 16 {
+		return nil, errors.New("Streaming does not support 16 bit output")
+	}
+	if o.Palette > 0 {
+		return nil, errors.New("Streaming does not support palette output")
+	}
+
+	if o.Width > 0 || o.Height > 0 {
+		img1 = resize.Resize(o.Width, o.Height, img1, o.Filter)
+		img2 = resize.Resize(o.Width, o.Height, img2, o.Filter)
+	}
+
+	mask := o.Mask
+	if mask == nil {
+		mask = DefaultMask
+	}
+	b1, b2 := img1.Bounds(), img2.Bounds()
+	w, h := b1.Dx(), b1.Dy()
+	if b2.Dx() > w {
+		w = b2.Dx()
+	}
+	if b2.Dy() > h {
+		h = b2.Dy()
+	}
+	return &MergeSource{
+		img1:   img1,
+		img2:   img2,
+		scale1: o.Brightness1,
+		scale2: o.Brightness2,
+		lut1:   [3]*[256]uint8{o.Range1.R.lut(), o.Range1.G.lut(), o.Range1.B.lut()},
+		lut2:   [3]*[256]uint8{o.Range2.R.lut(), o.Range2.G.lut(), o.Range2.B.lut()},
+		gray:   o.Gray,
+		mask:   newTiledMask(mask),
+		rect:   image.Rect(0, 0, w, h),
+	}, nil
+}
+
+// ColorModel implements image.Image.
+func (s *MergeSource) ColorModel() color.Model { return color.RGBAModel }
+
+// Bounds implements image.Image.
+func (s *MergeSource) Bounds() image.Rectangle { return s.rect }
+
+// At implements image.Image. Reading whole rows with ReadRow is much faster.
+func (s *MergeSource) At(x, y int) color.Color {
+	if !image.Pt(x, y).In(s.rect) {
+		return color.RGBA{}
+	}
+	var pix [4]uint8
+	s.merge(y, x, x+1, pix[:])
+	return color.RGBA{pix[0], pix[1], pix[2], pix[3]}
+}
+
+// ReadRow implements gamapng.RowImage.
+func (s *MergeSource) ReadRow(y int, dst []uint8) {
+	s.merge(y, s.rect.Min.X, s.rect.Max.X, dst)
+}
+
+// Opaque reports whether every pixel of the merged image is opaque. It is
+// true without merging any pixels when both images are opaque and cover
+// the whole source.
+func (s *MergeSource) Opaque() bool {
+	covers := func(img image.Image) bool {
+		o, ok := img.(interface{ Opaque() bool })
+		return ok && s.rect.In(img.Bounds()) && o.Opaque()
+	}
+	if covers(s.img1) && covers(s.img2) {
+		return true
+	}
+	row := make([]uint8, 4*s.rect.Dx())
+	for y := s.rect.Min.Y; y < s.rect.Max.Y; y++ {
+		s.ReadRow(y, row)
+		for i := 3; i < len(row); i += 4 {
+			if row[i] != 0xff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// merge stores the merged pixels between x0 and x1 on row y in dst.
+func (s *MergeSource) merge(y, x0, x1 int, dst []uint8) {
+	row1 := make([]uint16, 4*(x1-x0))
+	row2 := make([]uint16, 4*(x1-x0))
+	readRow(s.img1, y, x0, x1, row1)
+	readRow(s.img2, y, x0, x1, row2)
+	lo1, hi1 := inBounds(s.img1, y, x0, x1)
+	lo2, hi2 := inBounds(s.img2, y, x0, x1)
+	s.level(row1, lo1, hi1, s.scale1, &s.lut1)
+	s.level(row2, lo2, hi2, s.scale2, &s.lut2)
+	blendRow(dst, row1, row2, s.mask, x0, y)
+}
+
+// inBounds returns the part of the pixels between x0 and x1 on row y that
+// lie inside img, as indices from x0. lo equals hi if there are none.
+func inBounds(img image.Image, y, x0, x1 int) (lo, hi int) {
+	b := img.Bounds()
+	if y < b.Min.Y || y >= b.Max.Y {
+		return 0, 0
+	}
+	lo, hi = b.Min.X-x0, b.Max.X-x0
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > x1-x0 {
+		hi = x1 - x0
+	}
+	if lo > hi {
+		lo = hi
+	}
+	return lo, hi
+}
+
+// level converts, brightens and levels a row read by readRow, rounding to
+// 8 bits after each step like Grayscale, ScaleBrightness and LevelChannels.
+// Only the pixels between lo and hi, those inside the image, are changed,
+// which leaves the transparent padding outside of it alone like Build does.
+func (s *MergeSource) level(row []uint16, lo, hi int, scale float64, lut *[3]*[256]uint8) {
+	row = row[4*lo : 4*hi]
+	if s.gray {
+		grayRow(row)
+		for i, v := range row {
+			row[i] = v >> 8 * 0x101
+		}
+	}
+	for i := 0; i < len(row); i += 4 {
+		for c, t := range lut {
+			v := uint8(row[i+c] >> 8)
+			if scale != 0 && scale != 1 {
+				v = brighten(row[i+c], scale)
+			}
+			row[i+c] = uint16(t[v]) * 0x101
+		}
+		row[i+3] = row[i+3] >> 8 * 0x101
+	}
+}
@@ -0,0 +1,71 @@
+package dualpng
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestMergeSourceMatchesBuild(t *testing.T) {
+	big, small := testImages(61, 37), testImages(40, 52)
+	levels, err := ParseLevels("0-230@0.5,10-220,0-200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		img1, img2 image.Image
+		opts       func(o *Options)
+	}{
+		{"default", big["RGBA"], big["YCbCr"], func(o *Options) {}},
+		{"img1 larger", big["NRGBA"], small["RGBA"], func(o *Options) { o.Mask = [][]float64{{0.5}} }},
+		{"img2 larger", small["YCbCr"], big["NRGBA"], func(o *Options) { o.Mask = [][]float64{{0.5}} }},
+		{"unequal overlap", big["RGBA"], small["Gray16"], func(o *Options) { o.Mask = [][]float64{{1, 0.25}, {0.6, 0}} }},
+		{"brightness and levels", small["NRGBA"], big["YCbCr"], func(o *Options) {
+			o.Brightness1, o.Brightness2 = 1.4, 0.6
+			o.Range1 = levels
+			o.Mask = [][]float64{{0.5}}
+		}},
+		{"gray", big["YCbCr"], small["NRGBA"], func(o *Options) {
+			o.Gray = true
+			o.Brightness2 = 1.2
+			o.Mask = [][]float64{{0.5}}
+		}},
+		{"resized", big["RGBA"], small["NRGBA"], func(o *Options) { o.Width = 30 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions()
+			tt.opts(&o)
+			built, err := Build(tt.img1, tt.img2, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, ok := built.(*image.RGBA)
+			if !ok {
+				t.Fatalf("Build returned %T", built)
+			}
+			src, err := NewMergeSource(tt.img1, tt.img2, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src.Bounds() != want.Bounds() {
+				t.Fatalf("bounds %v, want %v", src.Bounds(), want.Bounds())
+			}
+			b := want.Bounds()
+			row := make([]uint8, 4*b.Dx())
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				src.ReadRow(y, row)
+				wantRow := want.Pix[want.PixOffset(b.Min.X, y):][:len(row)]
+				if !bytes.Equal(row, wantRow) {
+					for i := range row {
+						if row[i] != wantRow[i] {
+							x := i / 4
+							t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, row[4*x:4*x+4], wantRow[4*x:4*x+4])
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -13,7 +13,7 @@
 // static/js/uikit.min.js
 // DO NOT EDIT!
 
-package main
+package webui
 
 import (
 	"github.com/elazarl/go-bindata-assetfs"
@@ -179,7 +179,7 @@ func staticImagesPlaceholder_oldPng() (*asset, error) {
 	return a, nil
 }
 
-var _staticIndexHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xec\x5a\x5f\x6f\xe3\xb8\x11\x7f\x0f\x90\xef\x30\xe5\xed\x21\x16\x1a\xc9\xb2\x6e\x53\xa0\x8e\xed\x22\x45\xd2\xde\x02\x77\xdd\xf6\x76\x0f\xfb\x50\xf4\x81\x96\x68\x99\x6b\x8a\xd2\x51\x54\x9a\xf4\x2e\xdf\xbd\x20\x25\xd9\xfa\x43\xcb\xcc\xae\xb3\xb7\xc1\xae\x1f\x82\x58\x9a\x3f\xfc\xfd\x66\x86\x33\x94\x3c\x5b\xcb\x84\x2d\x4e\x4f\x4e\x4f\x66\x6b\x82\xa3\xc5\xe9\x09\x00\xc0\x8c\x51\xbe\x01\x41\xd8\x1c\xe5\xf2\x9e\x91\x7c\x4d\x88\x44\xb0\x16\x64\x35\x47\xe3\x30\xcf\xc7\x05\xdd\x50\xe9\x25\x94\x7b\x61\x9e\x23\x6b\xb5\x04\x3f\x56\xe3\xfd\x2f\x05\x11\xf7\x6e\x41\x7b\xce\xf2\x50\xd0\x4c\x42\x2e\xc2\x39\x1a\xbf\xaf\x25\xb5\xd8\xfb\x1c\x2d\x66\xe3\x52\x60\x9f\xf8\x0e\x81\xb5\xb4\x4b\xc3\x94\xe7\xd6\x3a\xed\xa5\x77\x34\x66\xe3\x8a\xef\xd3\x93\xd9\x32\x8d\xee\x6b\x23\x7f\x70\x5d\xf8\xfe\xe6\xea\xfa\xe6\x27\x70\xdd\xfa\x62\x44\x6f\x81\x46\x73\xa4\x54\x88\x40\x10\x32\x9c\xe7\x73\x54\x6c\x5c\x46\xe3\xb5\xac\x29\xd1\xb2\xeb\x60\x71\x5d\x60\x96\xf1\x78\x36\x5e\x07\xb5\x85\x71\x44\x6f\xb5\xb3\xad\x93\x9f\xff\xf9\xc3\xeb\xab\x6b\xf8\xdb\xab\x9b\x1f\xae\xe1\xf5\x3f\x6e\x3a\xee\x76\x2e\xc2\x94\x15\x09\x77\x27\x08\x74\x88\xe6\xe8\xbf\x34\x92\xeb\x29\xbc\xfc\xf3\xb7\x97\x10\xd1\x3c\x63\xf8\x7e\x0a\x94\x33\xca\x89\xbb\x64\x69\xb8\xb9\x6c\x2d\xa8\x5e\x7c\x91\xb1\x14\x47\x93\xe6\xea\x33\x86\x43\xb2\x4e\x59\x44\x04\x14\x1b\x57\x92\x3b\xe9\x86\x84\x4b\x22\x9a\x16\x4a\x72\x33\xcc\x95\x8c\x0a\xc1\x1c\xa9\xbf\x53\x08\x59\x5a\x44\x6e\x69\x57\x93\x9b\x61\x6e\xd4\xdb\x79\xd4\x2e\x12\x1a\x45\x8c\xa0\xc5\xb5\x48\x33\xc0\x1c\x68\x82\x63\x62\x56\x57\x8b\x2f\x36\xee\x2a\x15\x89\x1b\x16\xb9\x4c\x93\x8e\x84\x96\xa2\x3c\x2b\x24\xc8\xfb\x8c\xcc\xd1\x8a\x32\x82\x20\x29\x98\xa4\x19\x23\x26\xe9\xce\x92\x54\x09\xa0\x45\x2a\x20\x27\x8c\x84\x12\x30\x28\x13\xe6\xe5\x54\x61\x6c\x3b\x4f\x62\xcd\x2f\x4d\xe2\x1d\xb9\x55\x5c\x34\x32\x54\xa5\xa4\xfe\x32\x7e\x7b\xf3\xe6\xed\x58\xcb\x36\x83\xd4\x30\x7c\x28\x57\xde\xbe\x7b\xdd\xc9\x95\x63\xe4\x45\xf0\x45\xe4\xc5\xa7\x4d\x87\xe0\x11\xe9\x10\x58\xa6\x43\x26\xd2\x58\x90\x3c\xd7\x3e\xea\x2f\x4b\xdc\xda\x94\xea\xcb\x08\x6e\x31\x2b\xc8\x1c\xf9\x08\x12\x7c\x37\x47\x13\xdf\x47\xb0\xa6\x51\x44\xf8\x62\x36\xae\xc5\xda\xb9\x56\x6d\x5e\x90\x66\x92\xa6\x3c\xdf\xbb\x2d\xad\x18\xb9\x73\x23\x2a\x48\xa8\xe4\xba\x9b\xd3\xc4\xf7\xbf\xbd\x44\x2a\x44\xb1\xa0\x51\x37\xed\x2a\x2b\xb5\x8b\x62\xe3\x6a\x35\x77\xe2\x06\xc6\x04\x5b\xc0\x4f\x98\xc7\x04\x26\x90\x4b\x2c\x64\x15\x8c\xd9\x52\xf4\xc8\xd7\x01\xaf\xcc\xf3\x22\x59\x12\xe1\xea\x6b\xa8\xca\x82\xf2\x5a\x93\x18\xc5\xa3\x50\xd6\x27\xda\xf6\x8a\x12\x16\xf5\x16\xd1\x58\x74\x9e\xe1\x50\xd5\x81\x31\xf8\x4d\x39\x46\x75\xaf\xe8\xd8\xb7\x50\xec\x38\x30\xf1\x51\xd3\x41\x78\x04\xc7\x63\x23\x78\xd9\xe2\x83\xf0\xe8\x09\xd9\x20\x3c\x3a\x0e\x17\x55\x6e\x04\xc7\xce\x8d\x36\x1b\xc1\x13\x67\x47\x70\xc4\xec\xd8\x32\x42\x78\x74\x44\x3e\x2e\x2e\x9a\xeb\x7d\xd2\xec\x08\x8e\x96\x1d\x7f\xc7\x49\x82\x0f\x91\xa0\xfc\xc6\x4a\xb0\x44\xb4\x07\xff\x77\xbe\x8f\x4c\x36\x1e\x03\xb8\xf2\x84\x51\x07\xfa\x51\xb0\xfe\x55\xa8\x69\x94\xab\xee\x30\xb1\x81\xbc\xdc\xca\x4f\x86\x80\x4f\x8e\x82\xba\xe1\xec\xa9\xc1\x07\x8f\x03\x1f\x7c\x4a\xf0\xc1\xb1\xc1\x57\x93\x50\xa7\xcd\x36\x6f\x1b\x2e\xef\x68\x7b\xa7\x1a\xef\x5e\xbe\x0c\xbc\xe9\x4e\x3d\xc4\xd8\x85\x2a\x93\x3d\x66\x2c\x79\x83\xde\xdc\xf3\x28\x4c\xdf\x13\xc5\xf6\x63\x40\xad\xb5\xc6\x10\xaa\xa3\x62\x3a\x8c\x73\xb6\x2c\xa4\x4c\x79\x99\x3c\x92\x27\x44\xa8\xc1\x71\x37\x7d\x55\xb7\xb7\xff\xb9\x99\xa0\x09\x16\xf7\xc6\x39\x0c\x12\x2c\x62\xca\x5d\x99\x66\xea\x4a\x76\x77\x89\x16\x3f\x2a\x8b\xb3\x71\xa9\x6d\x18\x3d\xcd\x13\xdb\xd0\x66\x2f\x48\x5e\x30\xe9\x66\x98\x5b\xcd\xd9\xad\x43\xc5\x3b\x2a\xd7\x69\x21\x21\x6e\xec\xd6\xa6\x79\xbe\x9a\xad\x4b\x57\x3c\xd5\xe2\xf5\x3c\x5d\x5e\x2c\x07\xea\xfa\x96\x1d\xd7\x47\x02\xa1\x5b\x0d\xe0\x2c\x63\x94\x44\xb6\x20\xf6\x42\x18\x04\x60\x06\xb4\xfb\xd6\x7a\x22\xd2\x90\x7f\x31\x5a\x15\x5c\x0f\xec\x30\x72\xe0\xd7\xb6\x71\x41\x56\x82\xe4\xeb\x57\xea\x5c\x92\x8f\x9c\xcb\x2e\x4f\x2f\x46\xe8\x9b\xe6\x24\xeb\x78\xe5\x16\x36\xfa\xb5\x8f\x52\x57\xcd\x14\xfc\xf3\xfe\xad\x70\xad\x6c\x4c\x61\xb7\x12\x72\x0e\x05\xed\x2d\x67\xb7\xac\x5f\x0a\x92\x4b\x9d\xb0\x7a\x55\x5d\x81\x07\x83\x13\xbd\x32\x7b\x1f\x1d\x68\xe5\x46\xe0\x78\xb7\x98\x8d\x0a\xea\x69\x30\xd6\x9e\x25\x51\x55\x66\xb8\x93\xe0\xbb\x29\x04\x17\x17\xa6\x5b\x94\xf7\xc9\x7a\xe8\xba\xdc\xad\x52\x8d\x47\x43\xf4\x7f\xde\x1c\x6f\x47\xc7\x0f\x63\xb8\xca\xad\xe0\xa5\x29\xbb\x3e\x05\xfd\xc1\xe1\xfc\xff\xac\x03\x10\x7c\x74\x92\xff\xfe\x21\x78\xd6\x15\x10\x7c\x64\x05\xec\x67\xb8\x0e\x8c\x99\xe3\x63\xd0\xdf\x9c\xe3\x9f\x63\x00\x7a\x87\x9e\x8f\x09\x81\xef\xf9\xc6\x3c\x37\xb1\x09\xdb\x08\xec\x8f\x5b\xd7\xd8\x83\x33\x14\x80\xe0\x99\x07\x20\x78\x9e\x01\xd0\xe7\xf7\xe7\xc8\x7c\xe3\x11\xc7\xd1\x67\x9b\x41\xc2\xff\xe4\xfb\xa6\x7b\xf5\x66\xf5\x5d\xef\xee\x83\x61\xfa\x34\x54\x42\x7d\x30\x72\xbc\x94\x8f\x50\xc8\x68\xb8\x41\xe7\x2d\x3a\xfb\x76\x6e\xb1\x80\xc6\xe8\xbd\xa4\x31\xcc\x61\x85\x59\x4e\xfa\x9b\x9d\xd7\x3c\x0e\x38\xea\x1b\xfd\x1f\x5e\x32\x32\x72\x9a\x56\x3b\x8b\xdd\x46\xa4\x33\x4f\x77\x23\xa3\x10\xe8\xf7\x40\xce\xbf\xfd\xff\x78\x39\x91\x57\x52\x0a\xba\x2c\x24\x19\xa1\x5c\x84\xe8\x1c\x7a\xaf\x8c\xfe\x82\xe0\x8f\xf0\x23\x96\x6b\x4f\x60\x1e\xa5\xc9\xc8\x31\xed\xd0\xfa\x6d\x82\xb5\xd1\xc0\xce\x68\xf3\xb4\x32\x60\xbb\x77\x82\x79\x8c\xf5\xfa\xd4\x66\x69\xbf\x12\x1f\xf6\xf0\xb0\x27\x34\xcd\x8a\xeb\x45\xc6\xcb\xd2\x5c\x8e\xd0\x58\x67\x97\x76\x86\xce\x4d\x85\xa5\xfd\x4f\xcd\xb5\xe5\xc0\x6f\xbf\x01\xf2\x91\x21\xef\xab\xb3\xb9\x52\x6b\x3c\x4d\x39\xa8\x56\x3e\xa4\x28\xf5\x9a\x0f\x2c\x0e\x2a\x8a\xf2\x54\x33\x1d\x3a\xe6\x0c\xa9\x07\x5d\xf5\xfe\x00\x39\xe8\x9d\xf0\x68\xba\x7f\xfc\x1f\xf4\xdc\x56\xed\xce\x4d\x03\xaa\x8d\x36\x3f\x1d\xea\xfb\x56\x26\x82\xae\x89\xc0\xc2\xc4\x83\xe3\x45\x29\x27\x43\x27\x6e\x78\xf2\xe2\x82\x27\x2f\x30\xe8\x0e\x8a\xad\x8a\x6b\xa0\x7f\xd1\x83\xaf\xb6\x62\x9a\xa8\xfd\xb7\xb5\x17\xf6\x85\x96\x58\x54\x42\xcd\x37\x9e\x4a\xb6\xb3\x90\x9f\x5f\x6d\xa8\xf4\xca\x37\xcf\xa3\xb3\x6f\xaa\x9f\x3c\x9c\x19\x8b\xb7\x10\x6c\x0a\x67\xe3\x52\x66\xb7\xc7\x9e\x19\x32\x81\xe3\x84\x4c\x01\xd1\x24\x36\xe5\x49\xfd\x5b\x83\x69\xd9\x46\x4c\x99\x44\x56\xa9\x20\x6f\x74\x32\xb7\xd2\x01\xc2\x94\xe7\x29\x23\x1e\x4b\xe3\xd1\xd9\x4e\xec\xec\x1c\xb0\x88\x8b\x84\x70\x99\x3b\x97\xc6\x5e\x5c\x0a\x5f\x31\x66\x61\xf2\x8a\x31\x0b\x8b\x8a\x87\x41\x63\x4a\xc0\xc2\x0e\x11\x22\x15\x83\x86\xb4\x84\x85\xa5\x30\x4d\x32\x46\x24\x19\x34\x56\x0b\x59\x22\x7c\x53\xee\x65\x8d\xb9\x69\xef\xcc\x44\x93\xb8\x5d\x25\x67\xe5\xbb\xf3\xb3\x73\xa8\xff\x73\xcc\x9a\x3d\xda\xb4\xd7\xf6\x0a\xcd\x9a\x4b\x2c\x3c\x41\x92\xf4\x96\xf4\xdd\x0e\xe9\x24\xf8\x0e\xe6\x40\x3c\x99\x4a\xcc\x06\xe4\xf4\xcc\xa5\x25\xd5\xb2\x48\x64\x39\xf9\xd5\x75\x67\x47\x5c\x0b\x7e\xad\x6a\x8b\xfe\x89\x91\x28\xe1\x9b\x76\x21\x5a\x02\xa9\x34\x3f\x13\x1c\x75\xda\xf7\x77\x00\x0b\x28\x0d\x65\x1b\x38\xaa\x10\xf6\x26\xa5\x65\xed\xd8\xcf\xb4\x9e\x4c\xdf\x48\x41\x79\x3c\x72\xf6\x18\x3f\x78\x60\x52\x9f\x9c\xc8\xb7\x34\x21\x69\x21\x0f\xb5\xe0\xfa\xa3\xa2\x71\xa8\xde\xf7\x38\x7b\x38\x87\x89\xef\xfb\xc6\x83\xd4\x50\xaf\x74\x46\xef\xff\x55\x10\x71\x5f\x1e\x21\x3e\xb4\x6f\x06\x1f\xd2\x37\x2d\x3a\x67\xf0\x98\xce\x19\x7c\xed\x9c\x5f\x3b\x67\xf5\xf9\xda\x39\xb7\x9f\xaf\x9d\xf3\x4b\xee\x9c\x56\x9d\xea\x09\xdb\xab\xe1\xe9\xce\xe1\xf6\xfa\xec\x3a\x27\xe8\x97\xdf\xdb\xb7\xdd\xa7\x27\xb3\x71\xf5\x9b\x7e\xfd\x43\x7f\x99\xb0\xc5\xff\x03\x00\x00\xff\xff\xf6\x43\xbd\xcf\x69\x31\x00\x00")
+var _staticIndexHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xed\x5b\x5b\x53\xdb\x46\x14\x7e\xe7\x57\x6c\x94\x64\x90\x27\x48\xb6\x95\xd0\x4e\x8d\x4d\x27\x1d\x68\x93\x4e\x12\xd2\x40\x26\x0f\x9d\x3e\xc8\xd2\xda\x5e\xd0\x2d\xab\x55\x1a\x92\xf0\xdf\x7b\x76\xb5\x42\xb7\xd5\xc5\x60\x68\x68\x61\x06\xb0\xa5\x73\xfd\xf6\xec\xb9\xac\xe5\xe9\x8a\xf9\xde\xfe\xd6\xd6\x74\x85\x6d\x77\x7f\x0b\xc1\xcf\xd4\x23\xc1\x19\xa2\xd8\x9b\x69\x31\x3b\xf7\x70\xbc\xc2\x98\x69\x68\x45\xf1\x62\xa6\x0d\x9d\x38\x1e\x26\xe4\x8c\x30\xd3\x27\x81\x09\xef\xb4\xbe\x5c\xbe\xbd\x26\xc3\xe9\xc7\x04\xd3\x73\x23\x21\x55\x55\xb1\x43\x49\xc4\x50\x4c\x1d\xa0\x3c\xcd\x08\x05\xd5\x29\x10\x4d\x87\x29\x41\x03\x75\x6e\x7d\x5f\x62\x83\x38\x61\x10\xf7\x65\x29\x9b\x5d\x66\x98\x0e\x53\x9c\xb7\xa6\xf3\xd0\x3d\x97\x02\x1e\x18\x06\x7a\x71\xf8\xfc\xe0\xf0\x1d\x32\x0c\x79\xcd\x25\x9f\x10\x71\x67\x1a\x27\xc7\x54\x43\x8e\x67\xc7\xf1\x4c\x4b\xce\x0c\x8f\x2c\x57\x4c\x22\x21\x48\x57\xd6\xfe\x41\x62\x7b\x51\xb0\x04\xe9\x96\xe4\x1f\x82\x00\x50\x73\x29\xff\xfd\xdb\x57\x47\xcf\x0f\xd0\xaf\x2f\x0f\x5f\x1d\xa0\xa3\x37\x87\x65\x4d\xb9\x74\x27\xf4\x12\x3f\x30\xc6\x1a\x12\x8b\x32\xd3\xfe\x26\x2e\x5b\x4d\xd0\xb3\x9f\x1e\xef\x21\x97\xc4\x91\x67\x9f\x4f\x10\x09\x60\xf1\xb0\x31\xf7\x42\xe7\x6c\xaf\x68\x4b\x66\x76\x12\x79\xa1\xed\x8e\x8b\x76\x03\xa7\x83\x57\xa1\x07\xee\x20\x78\xcb\xf0\x67\x66\x38\x38\x60\xe0\x5d\x2e\x20\x05\x34\xb2\x03\x4e\xc2\x51\x9f\x69\xfc\xef\x04\xe4\x84\x89\x6b\xa4\x62\x05\xa0\x40\xa3\x62\xcb\xf5\x09\x05\x3e\x71\x5d\x0f\x6b\xfb\x07\x34\x8c\x10\xdc\x26\xbe\xbd\xc4\x4a\x6e\x6e\x39\x70\x2d\x42\xea\x1b\x4e\x12\xb3\xd0\x2f\x13\x08\x22\x12\x44\x09\x43\xec\x3c\x02\x60\x16\x04\x04\x23\x3f\xf1\x18\x89\x3c\xac\x20\xae\xd8\xc3\xc3\x5d\xdb\x0f\x29\x8a\xb1\x87\x1d\x86\x6c\xc4\x25\x28\x6d\x49\x17\xaf\xac\xd9\x5f\x0a\x60\xe1\x7f\x8e\xaa\x5c\x0f\xe1\x94\x26\x03\x50\xbc\x19\x9e\x1c\x1e\x9f\x0c\x05\x6d\x61\x71\x72\xb1\xed\xe1\x71\xf2\xe1\xa8\x1c\x1e\x1b\x08\x05\xeb\x3f\x1f\x0a\xb7\x18\x01\xd6\x1a\x11\x60\xf5\x88\x80\x88\x86\x4b\x8a\xe3\x58\xc8\xcf\xde\xcc\xed\x52\xda\xc9\x2e\x6b\xe8\x93\xed\x25\xe0\xf4\x08\x82\xdf\xfe\x3c\xd3\xc6\x23\x78\xb5\x02\x70\x71\x00\x8b\x91\x91\x15\x83\x4b\x66\x27\x14\x46\x8c\x40\x1e\x6d\x4a\x3d\x0b\x0f\x7f\x36\x5c\x42\x01\x1a\x20\xab\x26\x20\x50\xf3\x78\x4f\xe3\xeb\xb2\xa4\xc4\xad\xc4\x99\x14\x92\x29\x00\x22\xc1\x65\x8c\x0d\x4b\x15\x51\xfb\xe8\x9d\x1d\x2c\x31\x1a\x83\x0e\x9b\x32\xb9\x02\xd3\x39\xad\x42\x2e\xd6\x58\x0a\x0f\x12\x7f\x8e\xa9\x21\xae\x69\x72\xe1\xd3\x6b\x45\x48\x38\x82\x94\x0b\x1f\x0b\xd1\x0b\x82\x3d\x57\x53\xc4\x97\x14\x0a\x8a\x1d\x1e\xf5\xaa\x05\x2f\x92\x79\x44\x54\x81\x8a\xf4\x6e\xbe\xb2\x78\x05\x12\x19\x10\x38\x70\xd1\xc6\x70\xb0\x9e\x95\x90\x00\xd9\x37\x86\x03\xc8\xde\x00\x0a\x32\x1e\xac\x0d\xc7\x43\x19\x07\xeb\x46\x23\xc2\xda\x54\x44\x5c\x62\x01\xd0\x6e\x0e\x89\xdd\xdd\xa2\xad\x37\x18\x11\xd6\x66\x22\xe2\x37\xdb\xf7\xed\x0e\xf7\xb9\xce\x25\xa7\x4b\x7d\x69\xf0\xfc\x29\xe4\x47\x85\x88\x35\x5c\x95\x7a\x6c\xad\xe2\xf4\xf5\xbd\xfc\x85\xf2\x66\x32\xe0\xa9\x7f\xdc\xc3\xd9\xf9\x25\xf9\xb8\xcd\xe5\xf1\x06\xfc\x2d\xa8\xba\x51\xb7\xad\xb5\xdc\xb6\x6e\xcf\x6d\x6b\xa3\x6e\xcb\x96\xa6\x5c\x3a\x8b\x77\xeb\x57\x73\xb8\xde\xda\x0c\x5a\xb3\xa0\x09\xa9\x9c\x38\x6d\x6b\x44\x13\x91\xb2\x48\xb8\xf2\x1a\x9f\x92\x68\x6a\x01\x42\x48\x5a\xc3\x33\x4c\xb5\x7d\x17\x2f\x6c\xe8\xb1\xa7\xc3\xf4\x46\x83\xea\x61\x2a\x58\xe1\x9b\x02\x8d\x35\x7c\x46\x31\xf9\x82\x3b\x1d\xcf\x43\x45\xfa\xcd\xb9\xda\x42\x85\xf7\x4e\x24\xe0\xff\x37\x6f\x31\xf4\x61\x31\x61\xe7\xeb\x1b\x2d\x19\x5b\xed\x36\x7f\xdc\xbd\x34\x5d\xb6\x7f\xbc\x51\xc3\x11\xbf\x37\xda\x6d\x76\xa7\xeb\xd2\xad\x04\xf2\x07\xde\x14\xae\x01\x8c\x68\x22\xdb\xf0\xd8\x1d\x8d\x1a\x62\xb9\xe7\xc6\xbf\xce\x82\xbf\xc0\x3c\x57\xac\xe1\xce\x4a\x30\xb4\xc7\xe5\xa6\xbc\xe9\x72\x70\x3a\x4f\x18\x83\x7d\x2e\x92\x1e\x0b\x7c\x4c\xf9\x00\x93\x27\x0a\x79\xfb\xf2\x15\xcc\x1f\x30\xd7\xd0\x73\xe5\x58\x00\x91\x48\x97\x24\x30\x58\x18\xf1\x2b\xd1\x67\x18\x44\x5f\x73\x89\xd3\x61\xca\x5d\x1b\x81\x94\xe3\x43\x4b\x37\x02\x23\x0d\x24\x21\x03\x90\xee\x33\xe8\x95\x06\xda\x0f\x84\xad\x42\x58\x81\x65\xa1\xa3\x50\x8c\x93\x72\xba\x4b\x15\x05\xa1\xa0\xce\x26\xba\xf4\x62\x3a\xd2\x65\xb7\x7a\x40\xbc\x19\xfb\x45\x27\x84\xec\x28\xf2\x08\x76\x7b\xda\xdf\x68\x7d\x8b\xed\x2a\x57\xb2\xd7\xc5\x83\xb6\x9c\xf2\x91\xbe\x48\x02\x31\x2c\x22\x7d\x80\xbe\x96\x84\x52\xbc\x00\xbd\xab\x97\x7c\x16\x8e\xf5\xc1\x5e\x19\x9a\x47\xba\xf6\xb0\x38\x47\x0d\xcc\xb4\xc8\xea\x5f\x6b\x9e\x89\x9d\x31\x41\xa3\x9d\xda\x1d\x67\xc5\x25\x4c\x50\x6e\x04\xde\x41\x09\xa9\x5a\x92\x5b\xf4\x31\xc1\x31\x13\x71\xc9\x0d\xaa\xde\xbf\xa8\x6b\x10\x46\xf5\x56\x50\x71\x2a\xdd\xe7\x03\x13\x1c\xd0\x13\x62\x0a\x3f\x7a\xaa\x85\x7c\x0e\xfb\xa8\x7e\x03\x12\xfe\x04\x41\x2b\xaf\xb8\x43\x82\x1a\x46\x17\x15\x6d\xb9\x7d\xbc\x45\x6f\x81\xfc\xfb\x05\xf6\x72\x6e\xb9\x0a\xac\x32\x92\x60\x28\xbc\x7d\xc8\xad\xce\x38\xff\x6e\x41\xb7\xae\x19\xcd\xff\x2a\xec\x77\x35\xd2\xad\x6b\x45\x7a\x23\xac\xd9\x5a\x28\x81\xbd\x36\xe4\xc5\x99\xf1\x8e\x81\x5e\x9b\xac\xaf\x0e\x3b\xf4\xe1\xe3\x7e\x18\xe6\xa8\x37\xae\xd4\xb8\x8a\x7a\x0b\xe8\xd6\xdd\x05\xdd\xba\x63\xa0\x8b\xd3\xa0\x3b\x86\x76\xe1\xa4\x6c\xb3\x4d\x49\x1b\xc8\x3f\x8c\x46\xa3\xe6\x34\xf4\x74\xa4\xc8\x2a\x65\xb0\xcd\x25\x66\xbf\x1f\x1f\xbd\xd1\xb5\x61\x36\xdb\x6b\x3b\x05\xb7\x03\xdb\xc7\xb1\xca\x6d\x71\xc3\x5c\x84\xf4\xd0\x76\x56\x7a\x99\xa1\x0d\xa6\xd2\xc9\xc9\xc0\x84\xbe\x1b\x52\xb1\x0e\x77\xe4\xc9\xc8\xbe\x44\x4f\xc8\x31\x79\xa7\x9e\xbe\x54\xa1\x58\xb9\xa6\xca\x99\x45\x75\x3b\xe8\x61\xf5\xfc\x22\xbf\x54\x3a\x1d\x18\x98\x61\xa0\x6b\x69\x94\x01\x1c\xc5\xf8\x51\xb5\xda\x97\x03\x9e\xe4\xf3\x88\x73\xd6\xc1\xf6\xc9\xa6\xa8\x30\x4b\xcc\xc9\x12\xcd\xd0\xc2\xf6\x62\x5c\xf3\xc1\x2c\x0e\x37\x03\xfe\x8e\x7c\xb1\xe7\x1e\x44\x72\x2e\xb2\xb4\xb0\x97\x8b\x51\x19\x12\x2a\xab\xc2\x2d\x17\x1f\xa1\x0e\xfe\x1c\xfd\x65\xc6\x98\x3d\x67\x8c\x12\x18\x28\xb1\xae\xc1\x64\x03\x0e\xd4\x3e\x6d\xfd\x59\x43\x4f\xd0\x6b\x9b\xad\x4c\xa8\xa2\x6e\xe8\xeb\x03\x05\xe4\xe2\x43\xb9\xde\x32\xad\x5e\x32\x8b\x63\x57\x8b\xe8\xda\x28\xb6\x86\xf0\x6c\xf0\xec\x29\x5e\x92\xb7\x2a\xb8\x50\xac\x89\x0c\xb7\xe3\x08\x3b\xb5\x15\xe1\x41\xc1\x83\x1d\x42\x41\xb1\x55\xf8\xae\xa8\x58\x4f\x16\x48\x7f\xd0\xb4\xe3\x28\x66\x09\x0d\x90\xa6\x55\x76\xc9\x96\x82\x48\x68\x7d\x82\xb4\x09\x77\x47\x2f\x28\xcf\x4f\xfa\xa4\x01\xe8\xdb\x37\xa4\x8d\xb4\x81\x92\xba\xb2\x89\x0a\x0c\xfc\x60\xad\x03\x99\x72\x96\xae\x06\xab\x19\x85\x31\x83\x2c\x25\x36\x9a\x58\x02\x58\x92\xba\xd3\x62\x51\x26\xea\x84\x9c\xd9\x5e\x4f\x98\xf2\xb0\x85\x73\x15\xce\xc4\xba\xb8\xd2\xf3\xa6\x94\xad\x78\xf6\xd4\xc5\x47\xd3\x01\x76\xd2\x36\xd1\xb6\x70\x5b\x55\x6e\x6b\x1d\x6e\x3e\xe3\x4d\x9a\x47\xbe\x36\xbd\x65\x4e\xab\x37\x67\xa1\xf9\x9b\xb4\x75\x83\x7d\x24\x58\x93\xb6\xd6\xa6\x59\x82\x0c\xd0\x49\x79\xff\x55\xab\xa3\xe9\x86\x01\x6e\x39\x75\xb9\xf1\x74\x74\xe3\x29\xa9\x5a\x27\x0b\x1b\xb1\xe0\xf7\x23\x55\x66\xe2\x47\x60\xb3\x72\xd5\xa8\xd1\xcc\xe1\x57\x26\xaf\xc2\x63\x16\x9c\xb4\x6c\xc2\xfb\x97\xfc\x19\xb5\xf4\x19\x17\x7d\xfb\xa1\x7c\x9c\x6a\x5b\xb5\xa3\x13\xea\x4d\xd0\xf6\x30\x25\xc9\x4b\xd1\xf6\x8e\xb2\x25\x99\x20\xfe\x00\x89\x22\x00\xb2\x07\x99\x26\x69\x95\x55\xc4\x18\x86\x66\x06\x1f\x8b\x20\x2f\x45\x00\xe2\x0f\xc7\x85\x1e\x36\xbd\x70\xa9\x6f\xe7\x64\x60\xad\x4d\x97\x89\x8f\x03\x16\x0f\xf6\x54\x3d\x5d\x4a\xfb\xdc\xf3\x7a\x48\x04\xaa\x6e\x81\x1c\x82\x56\x59\x9c\xa0\x5b\x0c\xa6\x34\xa4\xad\x72\x04\x45\xb7\x20\x27\xf4\x01\x52\x86\x5b\x65\x65\x44\xfd\xdc\x3b\x4e\x93\x5b\xa1\xf1\x6e\xea\x26\x61\x9d\xcb\x9b\x62\x3b\x7d\x4a\x07\xf4\x64\xaf\x06\x4a\xc6\x1a\x62\x42\x67\xd9\x3c\x25\x23\xc4\x32\xb4\x5f\x7e\xf8\x09\xd7\x95\xb6\xb0\x40\xa7\x0e\x9b\x02\x9b\x2c\x64\xb6\xd7\x4c\x26\xba\x76\x41\xc8\x6d\xc2\x6e\xaf\xb1\x21\xdb\x66\xbd\x10\x2b\x39\x9e\x71\xf6\xf4\xfb\x26\x9d\xe0\xa4\x87\xe5\x7d\xd7\xcf\x07\xc9\xf8\x1d\xb8\x90\x05\x79\x7d\xb3\x77\x7b\x51\xe0\xed\xe1\x09\x0f\xfb\xc6\x20\xec\xb7\x51\xfa\xb7\xf7\x80\xd5\x31\x70\x05\x4b\x28\x22\x57\x1a\xad\xc5\x68\x8b\xd9\x09\xf1\x71\x98\x30\xbd\x1b\x99\x6c\x15\xba\x76\xb6\x5a\xd5\xc5\x0e\xff\xc4\x6c\xa4\x1a\x16\x9b\x4b\xe0\x40\x3f\xfd\x83\x3f\xd2\xcc\x67\xa8\xab\x55\x43\x6b\xfd\x6a\xd8\x5d\x0e\xad\x35\xca\xa1\x75\x5f\x0e\xef\xcb\xe1\x7d\x39\xbc\x2f\x87\xff\xbb\x72\xd8\xa7\x00\xdd\x5c\xc9\xb4\xae\x50\x32\xef\x50\x39\x94\xcf\x9e\xc9\xe7\x12\xb6\xa6\xc3\xf4\x5b\x3d\xfc\x6b\x3e\xfc\x6b\x55\xff\x00\xbb\x08\xf5\x56\x5d\x35\x00\x00")
 
 func staticIndexHtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -194,7 +194,7 @@ func staticIndexHtml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "static/index.html", size: 12649, mode: os.FileMode(438), modTime: time.Unix(1517385488, 0)}
+	info := bindataFileInfo{name: "static/index.html", size: 13661, mode: os.FileMode(438), modTime: time.Unix(1792173911, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -1,8 +1,9 @@
-package main
+// Package webui serves a web page for merging images with drag and drop.
+package webui
 
 import (
+	"encoding/json"
 	"errors"
-	"flag"
 	"fmt"
 	"image"
 	_ "image/gif"
@@ -15,32 +16,26 @@ import (
 	"time"
 
 	"github.com/Necroforger/dualpng"
-	"github.com/nfnt/resize"
 
 	"github.com/gorilla/mux"
 )
 
 //go:generate go-bindata-assetfs static/...
 
-// Flags
-var (
-	Port         = flag.String("p", "8800", "Server port")
-	Dir          = flag.String("d", "", "Asset directory, If none provided, the embedded ui will be run")
-	SessionLimit = flag.Int("-session-limit", 10, "Controls how many sessions can exist at time.")
-)
-
 // Session represents websocket connection information.
 type Session struct {
 	sync.RWMutex
-	ID     string
-	Img1   image.Image
-	Img2   image.Image
-	Result image.Image
-	Gamma  int
+	ID      string
+	Img1    image.Image
+	Img2    image.Image
+	Result  image.Image
+	Options dualpng.Options
 }
 
 // Sessions contains all the connected sessions.
-var sessions = []*Session{}
+var sessions = []*Session{
+	{ID: "TEST"},
+}
 
 func findSession(ID string) (*Session, error) {
 	for _, v := range sessions {
@@ -112,7 +107,7 @@ func ResultHandler(w http.ResponseWriter, r *http.Request) {
 	case "nogamma":
 		writePNG(w, s.Result)
 	default:
-		writeGAMApng(w, s.Result, s.Gamma)
+		writeGAMApng(w, s.Result, s.Options)
 	}
 }
 
@@ -168,50 +163,60 @@ func MergeHandler(w http.ResponseWriter, r *http.Request) {
 		return n
 	}
 
-	r1start := parseInt(r.Form.Get("r1start"))
-	r1end := parseInt(r.Form.Get("r1end"))
-	r2start := parseInt(r.Form.Get("r2start"))
-	r2end := parseInt(r.Form.Get("r2end"))
-	gamma := parseInt(r.Form.Get("gamma"))
-	width := parseInt(r.Form.Get("width"))
-	height := parseInt(r.Form.Get("height"))
-	brightness1 := parseFloat(r.Form.Get("brightness1"))
-	brightness2 := parseFloat(r.Form.Get("brightness2"))
-	if err != nil {
-		writeStatus(w, http.StatusInternalServerError)
-		return
+	parseRange := func(start, end string) dualpng.Levels {
+		rng, e := dualpng.NewRange(parseInt(start), parseInt(end), 0)
+		if e != nil {
+			log.Println("Error parsing range: ", e)
+			err = e
+		}
+		return dualpng.UniformLevels(rng)
 	}
 
-	s.Gamma = gamma
-
-	img1, img2 := s.Img1, s.Img2
-
-	if width > 0 || height > 0 {
-		img1 = resize.Resize(uint(width), uint(height), img1, resize.Lanczos3)
-		img2 = resize.Resize(uint(width), uint(height), img2, resize.Lanczos3)
+	opts := dualpng.DefaultOptions()
+	opts.Range1 = parseRange(r.Form.Get("r1start"), r.Form.Get("r1end"))
+	opts.Range2 = parseRange(r.Form.Get("r2start"), r.Form.Get("r2end"))
+	opts.Gamma = uint32(parseInt(r.Form.Get("gamma")))
+	opts.Width = uint(parseInt(r.Form.Get("width")))
+	opts.Height = uint(parseInt(r.Form.Get("height")))
+	opts.Brightness1 = parseFloat(r.Form.Get("brightness1"))
+	opts.Brightness2 = parseFloat(r.Form.Get("brightness2"))
+	if mask := r.Form.Get("mask"); mask != "" {
+		if e := json.Unmarshal([]byte(mask), &opts.Mask); e != nil {
+			log.Println("Error parsing mask: ", e)
+			err = e
+		}
 	}
-
-	// Scale brightness
-	if brightness1 != 1 {
-		img1 = dualpng.ScaleBrightness(img1, brightness1)
+	if pattern := r.Form.Get("pattern"); pattern != "" {
+		mask, e := dualpng.ParsePattern(pattern)
+		if e != nil {
+			log.Println("Error parsing pattern: ", e)
+			err = e
+		}
+		opts.Mask = mask
 	}
-	if brightness2 != 1 {
-		img2 = dualpng.ScaleBrightness(img2, brightness2)
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError)
+		return
 	}
 
-	s.Result = dualpng.MergeImages(
-		dualpng.LevelImage(
-			img1, uint8(r1start), uint8(r1end),
-		),
-		dualpng.LevelImage(
-			img2, uint8(r2start), uint8(r2end),
-		),
-		nil,
-	)
+	result, err := dualpng.Build(s.Img1, s.Img2, opts)
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest)
+		log.Println("Error merging images: ", err)
+		return
+	}
+	s.Options = opts
+	s.Result = result
 
 	writeStatus(w, 200)
 }
 
+// PatternsHandler lists the names of the mask patterns.
+func PatternsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(dualpng.Patterns())
+}
+
 // UploadHandler ...
 func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	var (
@@ -265,39 +270,42 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	writePNG(w, img)
 }
 
-func main() {
-	r := mux.NewRouter()
-	flag.Parse()
+// Server serves the web UI. The zero value serves the embedded UI.
+type Server struct {
+	// Dir is the directory the UI is served from.
+	// If empty, the UI embedded in the package is served.
+	Dir string
+}
 
-	sessions = append(sessions, &Session{
-		ID: "TEST",
-	})
+// Handler returns the handler serving the UI and its API.
+func (srv *Server) Handler() http.Handler {
+	r := mux.NewRouter()
 
 	var fileSystem http.FileSystem
-	if *Dir == "" {
+	if srv.Dir == "" {
 		fileSystem = assetFS()
 	} else {
-		fileSystem = http.Dir(*Dir)
+		fileSystem = http.Dir(srv.Dir)
 	}
 
 	r.HandleFunc("/image/{id}/{imgname}", ImageHandler)
 	r.HandleFunc("/result/{id}/{mode}", ResultHandler)
 	r.HandleFunc("/upload/{id}/{imgname}", UploadHandler).Methods("POST")
 	r.HandleFunc("/merge/{id}", MergeHandler).Methods("POST")
+	r.HandleFunc("/patterns", PatternsHandler)
 	r.PathPrefix("/").Handler(http.FileServer(fileSystem))
+	return r
+}
 
-	srv := &http.Server{
-		Handler:      r,
-		Addr:         "127.0.0.1" + ":" + *Port,
+// ListenAndServe serves the UI on the address, such as "127.0.0.1:8800".
+func (srv *Server) ListenAndServe(addr string) error {
+	hs := &http.Server{
+		Handler:      srv.Handler(),
+		Addr:         addr,
 		ReadTimeout:  time.Second * 10,
 		WriteTimeout: time.Second * 10,
 	}
-
-	log.Println("Starting server on port [" + ":" + *Port + "]")
-	log.Println("Connect to http://localhost:" + *Port + "/ in your browser")
-	if err := srv.ListenAndServe(); err != nil {
-		log.Println("error starting server: ", err)
-	}
+	return hs.ListenAndServe()
 }
 
 func writeStatus(w http.ResponseWriter, status int) {
@@ -310,7 +318,7 @@ func writePNG(w http.ResponseWriter, img image.Image) {
 	png.Encode(w, img)
 }
 
-func writeGAMApng(w http.ResponseWriter, img image.Image, gAMA int) {
+func writeGAMApng(w http.ResponseWriter, img image.Image, opts dualpng.Options) {
 	w.Header().Set("content-type", "image/png")
-	dualpng.Encode(w, img, uint32(gAMA))
+	opts.Encode(w, img)
 }